@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/oschrenk/arbol/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var accountsCmd = &cobra.Command{
+	Use:   "accounts",
+	Short: "List the configured accounts",
+	Long: `List each account with its root, how many repos it has on this machine,
+and where it is the default: everywhere (default = true) or on the machines
+in its hostnames.
+
+The account this invocation would use is marked with *, followed by why it
+was picked: --account, $ARBOL_ACCOUNT, this machine's hostname, default = true,
+or being the only account.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, active, reason, resolveErr := resolveAccount(cfg)
+
+		rows := [][]string{{"", "ACCOUNT", "ROOT", "REPOS", "DEFAULT"}}
+		for _, name := range cfg.AccountNames() {
+			account := cfg.Accounts[name]
+			marker := ""
+			if resolveErr == nil && name == active {
+				marker = "*"
+			}
+			rows = append(rows, []string{
+				marker,
+				name,
+				account.Root,
+				strconv.Itoa(len(account.GetRepos(""))),
+				defaultText(account),
+			})
+		}
+		writeTable(os.Stdout, rows)
+
+		fmt.Println()
+		if resolveErr != nil {
+			fmt.Printf("No active account: %v\n", resolveErr)
+			return nil
+		}
+		fmt.Printf("* %s is active (%s)\n", active, reasonText(reason))
+		return nil
+	},
+}
+
+// defaultText describes where account is the default: "yes" for
+// default = true, its hostnames, or "—"
+func defaultText(account *config.Account) string {
+	var parts []string
+	if account.Default {
+		parts = append(parts, "yes")
+	}
+	if len(account.Hostnames) > 0 {
+		parts = append(parts, "on "+strings.Join(account.Hostnames, ", "))
+	}
+	if len(parts) == 0 {
+		return "—"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// reasonText explains a config.AccountReason in terms of what the user set
+func reasonText(reason config.AccountReason) string {
+	switch reason {
+	case config.ReasonFlag:
+		return "--account"
+	case config.ReasonEnv:
+		return "$" + accountEnv
+	case config.ReasonHostname:
+		hostname, _ := os.Hostname()
+		return "hostname " + hostname
+	case config.ReasonDefault:
+		return "default = true"
+	}
+	return string(reason)
+}
+
+func init() {
+	rootCmd.AddCommand(accountsCmd)
+}
@@ -0,0 +1,24 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/oschrenk/arbol/internal/config"
+)
+
+func TestDefaultText(t *testing.T) {
+	cases := []struct {
+		account config.Account
+		want    string
+	}{
+		{config.Account{}, "—"},
+		{config.Account{Default: true}, "yes"},
+		{config.Account{Hostnames: []string{"a", "b"}}, "on a, b"},
+		{config.Account{Default: true, Hostnames: []string{"a"}}, "yes, on a"},
+	}
+	for _, c := range cases {
+		if got := defaultText(&c.account); got != c.want {
+			t.Errorf("defaultText(%+v) = %q, want %q", c.account, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/oschrenk/arbol/internal/config"
+	"github.com/oschrenk/arbol/internal/git"
+	"golang.org/x/term"
+)
+
+// passphraseEnv names the environment variable holding the passphrase of an
+// encrypted --ssh-key / ssh_key
+const passphraseEnv = "ARBOL_SSH_PASSPHRASE"
+
+// sshKeyFlag overrides the account's ssh_key for commands that clone
+var sshKeyFlag string
+
+// partialFlag makes every clone a partial clone, as partial = true does for
+// one repo
+var partialFlag bool
+
+// passphrases caches prompted passphrases by key path, so a sync cloning
+// many repos asks only once
+var passphrases = map[string]string{}
+
+// cloneOptions returns how to clone repo for the account: as a mirror,
+// sparse checkout or partial clone if it (or --partial) says so,
+// authenticated as the account and flags say (see cloneAuth)
+func cloneOptions(account *config.Account, repo config.Repo) (git.CloneOptions, error) {
+	opts, err := cloneAuth(account, repo.URL)
+	if err != nil {
+		return opts, err
+	}
+	opts.Mirror, opts.Sparse = repo.Mirror, repo.Sparse
+	opts.Partial = repo.Partial || partialFlag
+	return opts, nil
+}
+
+// cloneAuth returns how to authenticate cloning url for the account.
+// https URLs get a token from tokenEnv. For SSH, --ssh-key wins over the
+// account's ssh_key, and without either git.CloneWithOptions falls back to
+// the SSH agent and default keys.
+func cloneAuth(account *config.Account, url string) (git.CloneOptions, error) {
+	if git.IsHTTPURL(url) {
+		if env := tokenEnv(account, url); env != "" {
+			return git.CloneOptions{Token: os.Getenv(env)}, nil
+		}
+		return git.CloneOptions{}, nil
+	}
+
+	keyPath := sshKeyFlag
+	if keyPath == "" {
+		keyPath = account.SSHKey
+	}
+	if keyPath == "" {
+		return git.CloneOptions{}, nil
+	}
+	keyPath = config.ExpandPath(keyPath)
+
+	passphrase, err := keyPassphrase(keyPath)
+	if err != nil {
+		return git.CloneOptions{}, err
+	}
+	return git.CloneOptions{SSHKey: keyPath, Passphrase: passphrase}, nil
+}
+
+// tokenEnv names the environment variable holding the token for an https
+// url: the account's token_env, else GITHUB_TOKEN or GITLAB_TOKEN by host.
+// Other hosts get no default, so a token is never sent where it wasn't meant
+// to go.
+func tokenEnv(account *config.Account, url string) string {
+	if account.TokenEnv != "" {
+		return account.TokenEnv
+	}
+	host := strings.ToLower(git.HostFromURL(url))
+	switch {
+	case host == "github.com":
+		return "GITHUB_TOKEN"
+	case host == "gitlab.com", strings.HasPrefix(host, "gitlab."):
+		return "GITLAB_TOKEN"
+	}
+	return ""
+}
+
+// keyPassphrase returns the passphrase for an encrypted key from
+// $ARBOL_SSH_PASSPHRASE, prompting on the terminal when it's unset. An
+// unencrypted key needs none.
+func keyPassphrase(keyPath string) (string, error) {
+	if passphrase, ok := passphrases[keyPath]; ok {
+		return passphrase, nil
+	}
+	encrypted, err := git.KeyNeedsPassphrase(keyPath)
+	if err != nil {
+		return "", err
+	}
+	if !encrypted {
+		return "", nil
+	}
+	if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("SSH key %s is encrypted: set %s or run from a terminal", keyPath, passphraseEnv)
+	}
+	// stdout may be piped (arbol which), so the prompt goes to stderr
+	fmt.Fprintf(os.Stderr, "Passphrase for %s: ", keyPath)
+	data, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	passphrases[keyPath] = string(data)
+	return string(data), nil
+}
@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/oschrenk/arbol/internal/config"
+)
+
+func TestTokenEnv(t *testing.T) {
+	cases := []struct {
+		account config.Account
+		url     string
+		want    string
+	}{
+		{config.Account{}, "https://github.com/me/app.git", "GITHUB_TOKEN"},
+		{config.Account{}, "https://GitHub.com/me/app.git", "GITHUB_TOKEN"},
+		{config.Account{}, "https://gitlab.com/me/app.git", "GITLAB_TOKEN"},
+		{config.Account{}, "https://gitlab.example.com/me/app.git", "GITLAB_TOKEN"},
+		{config.Account{}, "https://git.example.com/me/app.git", ""},
+		{config.Account{TokenEnv: "WORK_TOKEN"}, "https://github.com/me/app.git", "WORK_TOKEN"},
+	}
+	for _, c := range cases {
+		if got := tokenEnv(&c.account, c.url); got != c.want {
+			t.Errorf("tokenEnv(%+v, %q) = %q, want %q", c.account, c.url, got, c.want)
+		}
+	}
+}
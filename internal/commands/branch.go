@@ -0,0 +1,203 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/oschrenk/arbol/internal/git"
+	"github.com/spf13/cobra"
+)
+
+var (
+	branchRemote   bool
+	branchMerged   bool
+	branchNoMerged bool
+)
+
+var branchCmd = &cobra.Command{
+	Use:   "branch [path...]",
+	Short: "List branches across repositories",
+	Long: `List the local branches of each cloned repository, grouped by repo, with
+the checked out one marked *.
+
+Use --remote to also list remote-tracking branches (origin/main, ...).
+Use --merged to list only branches merged into the repo's default branch,
+which can be deleted without losing commits, or --no-merged for those that
+aren't. The default branch is what the remote's HEAD points to, else main or
+master; the default branch itself isn't listed. Repos left without branches
+to list are left out.
+
+Without a path argument, lists all repos in the account.
+
+Examples:
+  arbol branch work                # branches of the repos under work
+  arbol branch --merged            # branches that are safe to delete
+  arbol branch --no-merged --remote`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		account, accountName, err := getAccount()
+		if err != nil {
+			return err
+		}
+		if branchMerged && branchNoMerged {
+			return fmt.Errorf("--merged can't be combined with --no-merged")
+		}
+
+		repos, filters, err := selectRepos(account, accountName, args)
+		if err != nil {
+			return err
+		}
+		if len(repos) == 0 {
+			if selection := describeSelection(filters); selection != "" {
+				fmt.Printf("No repos found %s in account '%s'\n", selection, accountName)
+			} else {
+				fmt.Printf("No repos configured in account '%s'\n", accountName)
+			}
+			return nil
+		}
+
+		ctx := cmd.Context()
+		var shown, failed int
+		for _, repo := range repos {
+			if ctx.Err() != nil {
+				return errInterrupted
+			}
+			if !git.Exists(repo.FullPath) {
+				continue
+			}
+			displayPath := repo.Path + "." + repo.Name
+
+			opCtx, cancel := opContext(ctx)
+			listing, err := listRepoBranches(opCtx, repo.FullPath, repo.Repo.RemoteName())
+			cancel()
+			if ctx.Err() != nil {
+				return errInterrupted
+			}
+			if err != nil {
+				// stderr, so a failing repo doesn't break up the list
+				fmt.Fprintf(os.Stderr, "error: %s: %v\n", displayPath, err)
+				failed++
+				continue
+			}
+			if len(listing.branches) == 0 {
+				continue
+			}
+
+			if shown > 0 {
+				fmt.Println()
+			}
+			shown++
+			header := colorize(colorCyan, displayPath)
+			if listing.target != "" {
+				verb := "merged into"
+				if branchNoMerged {
+					verb = "not merged into"
+				}
+				header += colorize(colorGray, fmt.Sprintf(" (%s %s)", verb, listing.target))
+			}
+			fmt.Println(header)
+			for _, line := range formatBranches(listing) {
+				fmt.Println(line)
+			}
+		}
+
+		if shown == 0 && failed == 0 {
+			fmt.Println("No matching branches")
+		}
+		if failed > 0 {
+			return fmt.Errorf("%s could not be read", pluralize(failed, "repo's branches", "repos' branches"))
+		}
+		return nil
+	},
+	ValidArgsFunction: completeRepoPath,
+}
+
+// branchListing is what branch lists for one repo
+type branchListing struct {
+	branches []string        // local, then remote-tracking ones with --remote
+	remote   map[string]bool // which of branches are remote-tracking
+	current  string          // checked out branch, "" on a detached HEAD
+	target   string          // default branch --merged/--no-merged compare against
+}
+
+// listRepoBranches lists the branches of the repository at path as the
+// flags say, with remote as the remote whose default branch --merged and
+// --no-merged compare against
+func listRepoBranches(ctx context.Context, path, remote string) (branchListing, error) {
+	var listing branchListing
+	local, current, err := git.BranchesContext(ctx, path)
+	if err != nil {
+		return listing, err
+	}
+	listing.branches, listing.current = local, current
+	listing.remote = make(map[string]bool)
+	if branchRemote {
+		remoteBranches, err := git.RemoteBranchesContext(ctx, path)
+		if err != nil {
+			return listing, err
+		}
+		for _, branch := range remoteBranches {
+			listing.remote[branch] = true
+		}
+		listing.branches = append(listing.branches, remoteBranches...)
+	}
+	if !branchMerged && !branchNoMerged {
+		return listing, nil
+	}
+
+	if listing.target, err = git.DefaultBranchContext(ctx, path, remote); err != nil {
+		return listing, err
+	}
+	merged, err := git.MergedBranchesContext(ctx, path, listing.target)
+	if err != nil {
+		return listing, err
+	}
+	listing.branches = filterMerged(listing.branches, merged, listing.target, remote, branchMerged)
+	return listing, nil
+}
+
+// filterMerged keeps the branches that are in merged, or with keepMerged
+// false those that aren't, leaving out target (a default branch like
+// origin/main) and its local counterpart
+func filterMerged(branches, merged []string, target, remote string, keepMerged bool) []string {
+	localTarget := strings.TrimPrefix(target, remote+"/")
+	var kept []string
+	for _, branch := range branches {
+		if branch == target || branch == localTarget {
+			continue
+		}
+		if slices.Contains(merged, branch) == keepMerged {
+			kept = append(kept, branch)
+		}
+	}
+	return kept
+}
+
+// formatBranches formats a line per branch of listing, the checked out one
+// marked like git branch does and remote-tracking ones grayed
+func formatBranches(listing branchListing) []string {
+	lines := make([]string, len(listing.branches))
+	for i, branch := range listing.branches {
+		switch {
+		case listing.remote[branch]:
+			lines[i] = "  " + colorize(colorGray, branch)
+		case branch == listing.current:
+			lines[i] = "* " + colorize(colorGreen, branch)
+		default:
+			lines[i] = "  " + branch
+		}
+	}
+	return lines
+}
+
+func init() {
+	branchCmd.Flags().BoolVar(&branchRemote, "remote", false, "Also list remote-tracking branches")
+	branchCmd.Flags().BoolVar(&branchMerged, "merged", false, "Only branches merged into the default branch")
+	branchCmd.Flags().BoolVar(&branchNoMerged, "no-merged", false, "Only branches not merged into the default branch")
+	branchCmd.Flags().StringArrayVar(&excludeFlags, "exclude", nil, "Skip repos matching this path or glob (repeatable)")
+	branchCmd.Flags().StringArrayVar(&tagFlags, "tag", nil, "Only repos with this tag (repeatable, all must match)")
+	branchCmd.Flags().BoolVar(&tagAnyFlag, "tag-any", false, "With several --tag, select repos with any of them")
+	rootCmd.AddCommand(branchCmd)
+}
@@ -0,0 +1,31 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFilterMerged(t *testing.T) {
+	branches := []string{"done", "main", "wip", "origin/main", "origin/old"}
+	merged := []string{"done", "main", "origin/main", "origin/old"}
+
+	if got := filterMerged(branches, merged, "origin/main", "origin", true); !slices.Equal(got, []string{"done", "origin/old"}) {
+		t.Errorf("merged: got %v", got)
+	}
+	if got := filterMerged(branches, merged, "origin/main", "origin", false); !slices.Equal(got, []string{"wip"}) {
+		t.Errorf("not merged: got %v", got)
+	}
+}
+
+func TestFormatBranches(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	listing := branchListing{
+		branches: []string{"main", "wip", "origin/main"},
+		remote:   map[string]bool{"origin/main": true},
+		current:  "wip",
+	}
+	want := []string{"  main", "* wip", "  origin/main"}
+	if got := formatBranches(listing); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
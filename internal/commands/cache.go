@@ -0,0 +1,161 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/oschrenk/arbol/internal/git"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cacheFlag   bool
+	noCacheFlag bool
+)
+
+// statusCacheFile is the name of the status cache in the cache directory
+const statusCacheFile = "status.json"
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the status cache",
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete the status cache",
+	Long: `Delete the statuses cached by 'arbol status --cache' (or cache = true in
+[settings]), so the next status reads every repo afresh.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := statusCachePath()
+		if err := os.Remove(path); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				fmt.Println("No status cache to clear")
+				return nil
+			}
+			return fmt.Errorf("failed to clear status cache: %w", err)
+		}
+		fmt.Printf("Cleared %s\n", path)
+		return nil
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
+
+// cacheDir returns arbol's cache directory: $XDG_CACHE_HOME/arbol, or
+// ~/.cache/arbol
+func cacheDir() string {
+	if xdg := os.Getenv("XDG_CACHE_HOME"); xdg != "" {
+		return filepath.Join(xdg, "arbol")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".cache", "arbol")
+}
+
+// statusCachePath returns where the status cache is kept
+func statusCachePath() string {
+	return filepath.Join(cacheDir(), statusCacheFile)
+}
+
+// cachedStatus is a repo's status as of its git.StatusFingerprint
+type cachedStatus struct {
+	Fingerprint string          `json:"fingerprint"`
+	Remote      string          `json:"remote"`
+	Status      *git.RepoStatus `json:"status"`
+}
+
+// statusCache holds statuses by repo directory between status runs. A nil
+// *statusCache caches nothing, so callers don't need to check whether
+// caching is on.
+type statusCache struct {
+	path    string
+	entries map[string]cachedStatus
+	changed bool
+}
+
+// openStatusCache reads the status cache, or returns nil without --cache
+// (or cache = true in [settings]) and with --no-cache. A missing or
+// unreadable cache starts out empty.
+func openStatusCache() *statusCache {
+	if !cacheFlag || noCacheFlag {
+		return nil
+	}
+	c := &statusCache{path: statusCachePath(), entries: make(map[string]cachedStatus)}
+	if data, err := os.ReadFile(c.path); err == nil {
+		if err := json.Unmarshal(data, &c.entries); err != nil {
+			c.entries = make(map[string]cachedStatus)
+		}
+	}
+	return c
+}
+
+// lookup returns the cached status of the repo at path against remote if
+// the repo hasn't changed since and it was read with the same --no-remote,
+// along with its current fingerprint ("" when it has none)
+func (c *statusCache) lookup(path, remote string) (*git.RepoStatus, string) {
+	if c == nil {
+		return nil, ""
+	}
+	fingerprint, err := git.StatusFingerprint(path, remote)
+	if err != nil {
+		return nil, ""
+	}
+	entry, ok := c.entries[path]
+	if !ok || entry.Fingerprint != fingerprint || entry.Remote != remote || entry.Status == nil ||
+		entry.Status.RemoteSkipped != noRemoteFlag {
+		return nil, fingerprint
+	}
+	return entry.Status, fingerprint
+}
+
+// store caches status for the repo at path, read when it had fingerprint
+func (c *statusCache) store(path, remote, fingerprint string, status *git.RepoStatus) {
+	if c == nil || fingerprint == "" || status == nil {
+		return
+	}
+	c.entries[path] = cachedStatus{Fingerprint: fingerprint, Remote: remote, Status: status}
+	c.changed = true
+}
+
+// save writes the cache back if anything was stored, replacing the file
+// atomically so concurrent runs don't read half of it. A failure is
+// reported on stderr and otherwise ignored, since the cache only saves time.
+func (c *statusCache) save() {
+	if c == nil || !c.changed {
+		return
+	}
+	if err := writeStatusCache(c.path, c.entries); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to write status cache: %v\n", err)
+	}
+}
+
+// writeStatusCache writes entries to path through a temporary file
+func writeStatusCache(path string, entries map[string]cachedStatus) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".status-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
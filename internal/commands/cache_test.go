@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/oschrenk/arbol/internal/git"
+)
+
+func TestStatusCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	repo := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+
+	cacheFlag, noCacheFlag = false, false
+	if openStatusCache() != nil {
+		t.Fatal("cache open without --cache")
+	}
+	cacheFlag = true
+	defer func() { cacheFlag = false }()
+
+	cache := openStatusCache()
+	status, fingerprint := cache.lookup(repo, "origin")
+	if status != nil || fingerprint == "" {
+		t.Fatalf("lookup on an empty cache = %v, %q, want a miss with a fingerprint", status, fingerprint)
+	}
+	cache.store(repo, "origin", fingerprint, &git.RepoStatus{Branch: "main"})
+	cache.save()
+
+	cache = openStatusCache()
+	if status, _ := cache.lookup(repo, "origin"); status == nil || status.Branch != "main" {
+		t.Errorf("lookup after save = %v, want the stored status", status)
+	}
+	if status, _ := cache.lookup(repo, "upstream"); status != nil {
+		t.Error("lookup against another remote hit the cache")
+	}
+
+	// Staging a file rewrites the index, which invalidates the entry
+	if err := os.WriteFile(filepath.Join(repo, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("git", "-C", repo, "add", "a.txt").CombinedOutput(); err != nil {
+		t.Fatalf("git add: %v: %s", err, out)
+	}
+	if status, _ := cache.lookup(repo, "origin"); status != nil {
+		t.Error("lookup hit the cache after the index changed")
+	}
+
+	noCacheFlag = true
+	defer func() { noCacheFlag = false }()
+	if openStatusCache() != nil {
+		t.Error("cache open with --no-cache")
+	}
+}
+
+func TestStatusCachePush(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	cacheFlag = true
+	defer func() { cacheFlag = false }()
+
+	run := func(dir string, args ...string) {
+		t.Helper()
+		args = append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	run(t.TempDir(), "init", "-q", "--bare", "-b", "main", remote)
+	repo := t.TempDir()
+	run(repo, "clone", "-q", remote, ".")
+	run(repo, "commit", "-q", "--allow-empty", "-m", "initial")
+	run(repo, "push", "-q", "origin", "main")
+	run(repo, "commit", "-q", "--allow-empty", "-m", "unpushed")
+
+	cache := openStatusCache()
+	_, fingerprint := cache.lookup(repo, "origin")
+	cache.store(repo, "origin", fingerprint, &git.RepoStatus{Branch: "main", Ahead: 1})
+	if status, _ := cache.lookup(repo, "origin"); status == nil {
+		t.Fatal("lookup missed right after store")
+	}
+
+	// A push moves only origin/main, which the ahead count comes from
+	run(repo, "push", "-q", "origin", "main")
+	if status, _ := cache.lookup(repo, "origin"); status != nil {
+		t.Errorf("lookup after a push = %+v, want a miss", status)
+	}
+}
@@ -0,0 +1,163 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/oschrenk/arbol/internal/git"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkoutCreate bool
+	checkoutForce  bool
+)
+
+var checkoutCmd = &cobra.Command{
+	Use:   "checkout <branch> [path...]",
+	Short: "Switch repositories to a branch",
+	Long: `Switch cloned repositories to a branch.
+
+A local branch is checked out if it exists, otherwise a branch of the same
+name on the repo's remote (default origin), tracking it. Repos without the
+branch fail unless --create is given, which starts it from the remote's
+default branch: what its HEAD points to, asked from the remote when the
+clone doesn't record it. A repo whose default branch can't be found fails
+rather than branching from whatever is checked out.
+
+Repos with uncommitted changes are skipped unless --force is given, in which
+case git carries the changes over (or refuses if they conflict). Nothing is
+ever discarded. Repos with stashed changes say so when switched, since the
+stash stays behind, shared by all branches.
+
+Without a path argument, switches all repos in the account.
+
+Examples:
+  arbol checkout main                          # switch everything back to main
+  arbol checkout feature/login work.backend    # repos under work.backend
+  arbol checkout feature/login work --create   # create where missing`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		account, accountName, err := getAccount()
+		if err != nil {
+			return err
+		}
+
+		branch := args[0]
+		repos, filters, err := selectRepos(account, accountName, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(repos) == 0 {
+			if selection := describeSelection(filters); selection != "" {
+				fmt.Printf("No repos found %s in account '%s'\n", selection, accountName)
+			} else {
+				fmt.Printf("No repos configured in account '%s'\n", accountName)
+			}
+			return nil
+		}
+
+		ctx := cmd.Context()
+		var switched, skipped, failed int
+
+		for _, repo := range repos {
+			if ctx.Err() != nil {
+				break
+			}
+			displayPath := repo.Path + "." + repo.Name
+
+			if !git.Exists(repo.FullPath) {
+				fmt.Printf("  skip   %s (not cloned)\n", displayPath)
+				skipped++
+				continue
+			}
+
+			opCtx, cancel := opContext(ctx)
+			status, err := git.StatusContext(opCtx, repo.FullPath, repo.Repo.RemoteName())
+			cancel()
+			if ctx.Err() != nil {
+				break
+			}
+			if err != nil {
+				fmt.Printf("  error  %s: %v\n", displayPath, err)
+				failed++
+				continue
+			}
+			if status.IsBare {
+				fmt.Printf("  skip   %s (bare repository)\n", displayPath)
+				skipped++
+				continue
+			}
+			if !status.IsDetached && status.Branch == branch {
+				fmt.Printf("  skip   %s (already on %s)\n", displayPath, branch)
+				skipped++
+				continue
+			}
+			if status.IsDirty && !checkoutForce {
+				fmt.Printf("  skip   %s (%s, use --force)\n", displayPath, pluralize(status.DirtyFiles, "dirty file", "dirty files"))
+				skipped++
+				continue
+			}
+
+			opCtx, cancel = opContext(ctx)
+			// The stash isn't per branch, so changes stashed on the old
+			// branch are easy to forget after switching
+			if stashes, _ := git.StashCountContext(opCtx, repo.FullPath); stashes > 0 {
+				fmt.Printf("  switch %s (%s)\n", displayPath, stashNote(stashes))
+			} else {
+				fmt.Printf("  switch %s\n", displayPath)
+			}
+			err = git.CheckoutContext(opCtx, repo.FullPath, repo.Repo.RemoteName(), branch, checkoutCreate)
+			cancel()
+			if ctx.Err() != nil {
+				break
+			}
+			if err != nil {
+				fmt.Printf("  error  %s: %v\n", displayPath, err)
+				failed++
+				continue
+			}
+			switched++
+		}
+
+		var summary []string
+		if switched > 0 {
+			summary = append(summary, fmt.Sprintf("%d switched", switched))
+		}
+		if skipped > 0 {
+			summary = append(summary, fmt.Sprintf("%d skipped", skipped))
+		}
+		if failed > 0 {
+			summary = append(summary, fmt.Sprintf("%d failed", failed))
+		}
+		if len(summary) == 0 {
+			summary = append(summary, "nothing to do")
+		}
+		if ctx.Err() != nil {
+			fmt.Printf("\nInterrupted, %d switched so far\n", switched)
+			fmt.Printf("Summary: %s\n", strings.Join(summary, ", "))
+			return errInterrupted
+		}
+		fmt.Printf("\nSummary: %s\n", strings.Join(summary, ", "))
+		if failed > 0 {
+			return fmt.Errorf("%s failed to switch", pluralize(failed, "repo", "repos"))
+		}
+		return nil
+	},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeRepoPath(cmd, nil, toComplete)
+	},
+}
+
+func init() {
+	checkoutCmd.Flags().BoolVar(&checkoutCreate, "create", false, "Create the branch from origin's default branch where it doesn't exist")
+	checkoutCmd.Flags().BoolVar(&checkoutForce, "force", false, "Also switch repos with uncommitted changes, carrying them over")
+	checkoutCmd.Flags().StringArrayVar(&excludeFlags, "exclude", nil, "Skip repos matching this path or glob (repeatable)")
+	checkoutCmd.Flags().StringArrayVar(&tagFlags, "tag", nil, "Only repos with this tag (repeatable, all must match)")
+	checkoutCmd.Flags().BoolVar(&tagAnyFlag, "tag-any", false, "With several --tag, select repos with any of them")
+	checkoutCmd.Flags().BoolVar(&includeDisabledFlag, "include-disabled", false, "Also select repos with enabled = false")
+	rootCmd.AddCommand(checkoutCmd)
+}
@@ -0,0 +1,191 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+
+	"github.com/oschrenk/arbol/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var configShowJSON bool
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the config file",
+}
+
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print the path of the config file",
+	Long: `Print the path of the config file arbol reads: --config if given,
+otherwise $XDG_CONFIG_HOME/arbol/config.toml (~/.config/arbol/config.toml).
+The file doesn't have to exist.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println(configPath())
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [file]",
+	Short: "Check a config file for errors",
+	Long: `Load a config file and check it the way every command does: TOML syntax,
+unknown or invalid repo fields, and path conflicts between repos and
+subpaths. Prints OK or the first problem and exits non-zero.
+
+Without a file, checks the config arbol would use (see 'arbol config path'),
+so a candidate config can be checked before swapping it in:
+
+  arbol config validate ~/new-config.toml`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := configPath()
+		if len(args) == 1 {
+			path = config.ExpandPath(args[0])
+		}
+
+		c, err := config.LoadFromPath(path)
+		if err != nil {
+			return err
+		}
+
+		repos := 0
+		for _, account := range c.Accounts {
+			for _, list := range account.Repos {
+				repos += len(list)
+			}
+		}
+		fmt.Printf("OK: %s (%s, %s)\n", path, pluralize(len(c.Accounts), "account", "accounts"), pluralize(repos, "repo", "repos"))
+		return nil
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the config as arbol resolves it",
+	Long: `Print every account with its root expanded, and each repo's dotted path,
+derived name, directory, and URL, after parsing and path expansion. Repos
+that don't apply on this machine are listed too, with the reason.
+
+With --account, only that account is shown. Use --json for tooling.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		names := cfg.AccountNames()
+		if accountFlag != "" {
+			if _, ok := cfg.Accounts[accountFlag]; !ok {
+				return fmt.Errorf("account '%s' not found", accountFlag)
+			}
+			names = []string{accountFlag}
+		}
+
+		hostname, _ := os.Hostname()
+		var accounts []jsonAccount
+		for _, name := range names {
+			account := cfg.Accounts[name]
+			entry := jsonAccount{
+				Name:         name,
+				Root:         account.Root,
+				ExpandedRoot: config.ExpandPath(account.Root),
+				Repos:        []jsonConfigRepo{},
+			}
+			repos := account.GetAllRepos("")
+			sort.Slice(repos, func(i, j int) bool {
+				return repos[i].Path+"."+repos[i].Name < repos[j].Path+"."+repos[j].Name
+			})
+			for _, repo := range repos {
+				entry.Repos = append(entry.Repos, jsonConfigRepo{
+					ID:       repo.Path + "." + repo.Name,
+					Name:     repo.Name,
+					FullPath: repo.FullPath,
+					URL:      repo.Repo.URL,
+					Skipped:  repo.Repo.SkipReason(runtime.GOOS, hostname),
+				})
+			}
+			accounts = append(accounts, entry)
+		}
+
+		if configShowJSON {
+			output, err := json.MarshalIndent(accounts, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(output))
+			return nil
+		}
+
+		for i, account := range accounts {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s: %s\n", account.Name, account.ExpandedRoot)
+			if len(account.Repos) == 0 {
+				fmt.Println("  (no repos)")
+				continue
+			}
+			rows := [][]string{{"  PATH", "NAME", "DIRECTORY", "URL", ""}}
+			for _, repo := range account.Repos {
+				note := ""
+				if repo.Skipped != "" {
+					note = colorize(colorGray, "skipped: "+repo.Skipped)
+				}
+				rows = append(rows, []string{"  " + repo.ID, repo.Name, repo.FullPath, repo.URL, note})
+			}
+			writeTable(os.Stdout, rows)
+		}
+		return nil
+	},
+}
+
+// saveConfig writes c to path, first copying the current file (if exists)
+// to path.bak, since saving drops its comments and formatting
+func saveConfig(c *config.Config, path string, exists bool) error {
+	if exists {
+		if _, err := backupConfig(path); err != nil {
+			return err
+		}
+	}
+	return c.Save(path)
+}
+
+// backupConfig copies the config file at path to path.bak and returns the
+// backup's path
+func backupConfig(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to back up config file: %w", err)
+	}
+	backup := path + ".bak"
+	if err := os.WriteFile(backup, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to back up config file: %w", err)
+	}
+	return backup, nil
+}
+
+// jsonAccount is an account in config show --json
+type jsonAccount struct {
+	Name         string           `json:"name"`
+	Root         string           `json:"root"`
+	ExpandedRoot string           `json:"expanded_root"`
+	Repos        []jsonConfigRepo `json:"repos"`
+}
+
+// jsonConfigRepo is a repo in config show --json
+type jsonConfigRepo struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	FullPath string `json:"full_path"`
+	URL      string `json:"url"`
+	Skipped  string `json:"skipped,omitempty"`
+}
+
+func init() {
+	configShowCmd.Flags().BoolVar(&configShowJSON, "json", false, "Print JSON instead of tables")
+	configCmd.AddCommand(configPathCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
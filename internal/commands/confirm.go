@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// yesFlag answers confirmation prompts with yes, for scripts
+var yesFlag bool
+
+// stdin reads prompt answers. It's shared so that answers piped in several
+// lines at once aren't lost to a reader's buffer between prompts.
+var stdin = bufio.NewReader(os.Stdin)
+
+// confirm asks prompt on stderr and reports whether the answer read from in
+// is yes. Anything else, including end of input, is no.
+func confirm(in *bufio.Reader, prompt string) bool {
+	return confirmDefault(in, prompt, false)
+}
+
+// confirmDefault is like confirm, but an empty answer (or end of input)
+// means def
+func confirmDefault(in *bufio.Reader, prompt string, def bool) bool {
+	choices := "[y/N]"
+	if def {
+		choices = "[Y/n]"
+	}
+	switch strings.ToLower(ask(in, prompt+" "+choices, "")) {
+	case "":
+		return def
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// ask prints prompt on stderr, with def in brackets if given, and returns the
+// trimmed answer read from in, or def for an empty one
+func ask(in *bufio.Reader, prompt, def string) string {
+	if def != "" {
+		fmt.Fprintf(os.Stderr, "%s [%s]: ", prompt, def)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s ", prompt)
+	}
+	answer, _ := in.ReadString('\n')
+	if answer = strings.TrimSpace(answer); answer != "" {
+		return answer
+	}
+	return def
+}
+
+// stdinIsTerminal reports whether a prompt can be answered interactively
+func stdinIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestConfirm(t *testing.T) {
+	tests := map[string]bool{
+		"y\n":     true,
+		"YES\n":   true,
+		" y \n":   true,
+		"y":       true,
+		"n\n":     false,
+		"\n":      false,
+		"":        false,
+		"yeah\n":  false,
+		"y\nn\n":  true,
+		"no\ny\n": false,
+	}
+	for input, want := range tests {
+		if got := confirm(bufio.NewReader(strings.NewReader(input)), "Delete?"); got != want {
+			t.Errorf("confirm(%q) = %v, want %v", input, got, want)
+		}
+	}
+
+	if !confirmDefault(bufio.NewReader(strings.NewReader("\n")), "Default?", true) {
+		t.Error("confirmDefault(empty, true) = false, want true")
+	}
+	if confirmDefault(bufio.NewReader(strings.NewReader("n\n")), "Default?", true) {
+		t.Error("confirmDefault(n, true) = true, want false")
+	}
+}
+
+func TestAskSharesReader(t *testing.T) {
+	in := bufio.NewReader(strings.NewReader("work\n\ny\n"))
+	if got := ask(in, "Account name", "default"); got != "work" {
+		t.Errorf("first answer = %q, want work", got)
+	}
+	if got := ask(in, "Root", "~/Projects"); got != "~/Projects" {
+		t.Errorf("empty answer = %q, want the default", got)
+	}
+	if !confirm(in, "Create it?") {
+		t.Error("third answer wasn't read as yes")
+	}
+}
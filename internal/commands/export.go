@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oschrenk/arbol/internal/config"
+	"github.com/oschrenk/arbol/internal/git"
+	"github.com/spf13/cobra"
+)
+
+const (
+	exportScriptFormat = "script"
+	exportCSVFormat    = "csv"
+)
+
+var exportFormat string
+
+var exportCmd = &cobra.Command{
+	Use:   "export [path...]",
+	Short: "Export the repos as a clone script or CSV",
+	Long: `Print the repos of the account for use without arbol.
+
+--format script (the default) prints a POSIX shell script that creates each
+repo's parent directory and clones it to the directory arbol would, with the
+same mirror, sparse and partial clone options. It's a fallback for machines
+without arbol, and documents what a sync would do. Credentials aren't part
+of it: clones use whatever git is set up with where the script runs.
+
+--format csv prints a path,url,fullpath line per repo, after a header, for
+spreadsheets and other tools.
+
+Repos that don't apply on this machine (disabled, or limited by os or
+hostnames) are left out. Without a path argument, exports all repos in the
+account.
+
+Examples:
+  arbol export > clone.sh             # clone script for another machine
+  arbol export work --format csv      # the repos under work as CSV`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if exportFormat != exportScriptFormat && exportFormat != exportCSVFormat {
+			return fmt.Errorf("invalid --format %q, must be %s or %s", exportFormat, exportScriptFormat, exportCSVFormat)
+		}
+		account, accountName, err := getAccount()
+		if err != nil {
+			return err
+		}
+
+		repos, filters, err := selectRepos(account, accountName, args)
+		if err != nil {
+			return err
+		}
+		if len(repos) == 0 {
+			// stderr, so an empty export doesn't end up in the script
+			if selection := describeSelection(filters); selection != "" {
+				fmt.Fprintf(os.Stderr, "No repos found %s in account '%s'\n", selection, accountName)
+			} else {
+				fmt.Fprintf(os.Stderr, "No repos configured in account '%s'\n", accountName)
+			}
+			return nil
+		}
+
+		if exportFormat == exportCSVFormat {
+			return writeExportCSV(os.Stdout, repos)
+		}
+		return writeExportScript(os.Stdout, accountName, repos)
+	},
+	ValidArgsFunction: completeRepoPath,
+}
+
+// writeExportScript writes a sh script cloning repos where arbol would,
+// making each parent directory once
+func writeExportScript(w io.Writer, accountName string, repos []config.RepoWithPath) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#!/bin/sh\n# Repos of account '%s', exported by arbol\nset -e\n", accountName)
+	made := make(map[string]bool)
+	for _, repo := range repos {
+		b.WriteString("\n")
+		if parent := filepath.Dir(repo.FullPath); !made[parent] {
+			made[parent] = true
+			fmt.Fprintf(&b, "mkdir -p %s\n", git.ShellQuote(parent))
+		}
+		b.WriteString(cloneLine(repo))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// cloneLine returns the git commands cloning repo as a sync would, mirror,
+// sparse and partial options included
+func cloneLine(repo config.RepoWithPath) string {
+	args := []string{"git", "clone"}
+	switch {
+	case repo.Repo.Mirror:
+		args = append(args, "--mirror")
+	case len(repo.Repo.Sparse) > 0:
+		args = append(args, "--sparse")
+	}
+	if repo.Repo.Partial {
+		args = append(args, "--filter=blob:none")
+	}
+	args = append(args, "--", git.ShellQuote(repo.Repo.URL), git.ShellQuote(repo.FullPath))
+	line := strings.Join(args, " ") + "\n"
+	if !repo.Repo.Mirror && len(repo.Repo.Sparse) > 0 {
+		dirs := make([]string, len(repo.Repo.Sparse))
+		for i, dir := range repo.Repo.Sparse {
+			dirs[i] = git.ShellQuote(dir)
+		}
+		line += fmt.Sprintf("git -C %s sparse-checkout set -- %s\n", git.ShellQuote(repo.FullPath), strings.Join(dirs, " "))
+	}
+	return line
+}
+
+// writeExportCSV writes a path,url,fullpath record per repo after a header
+func writeExportCSV(w io.Writer, repos []config.RepoWithPath) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"path", "url", "fullpath"})
+	for _, repo := range repos {
+		out.Write([]string{repo.Path + "." + repo.Name, repo.Repo.URL, repo.FullPath})
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	exportCmd.Flags().StringVar(&exportFormat, "format", exportScriptFormat, "Output format: script or csv")
+	exportCmd.Flags().StringArrayVar(&excludeFlags, "exclude", nil, "Skip repos matching this path or glob (repeatable)")
+	exportCmd.Flags().StringArrayVar(&tagFlags, "tag", nil, "Only repos with this tag (repeatable, all must match)")
+	exportCmd.Flags().BoolVar(&tagAnyFlag, "tag-any", false, "With several --tag, select repos with any of them")
+	rootCmd.AddCommand(exportCmd)
+}
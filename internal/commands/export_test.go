@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/oschrenk/arbol/internal/config"
+)
+
+func TestWriteExportScript(t *testing.T) {
+	repos := []config.RepoWithPath{
+		{Repo: config.Repo{URL: "git@github.com:acme/api.git"}, Path: "work", Name: "api", FullPath: "/src/work/api"},
+		{Repo: config.Repo{URL: "git@github.com:acme/web.git", Partial: true, Sparse: []string{"app", "it's"}}, Path: "work", Name: "web", FullPath: "/src/work/web"},
+		{Repo: config.Repo{URL: "https://github.com/me/old.git", Mirror: true}, Path: "me", Name: "old", FullPath: "/src/me/old"},
+	}
+
+	var buf bytes.Buffer
+	if err := writeExportScript(&buf, "home", repos); err != nil {
+		t.Fatal(err)
+	}
+	want := `#!/bin/sh
+# Repos of account 'home', exported by arbol
+set -e
+
+mkdir -p '/src/work'
+git clone -- 'git@github.com:acme/api.git' '/src/work/api'
+
+git clone --sparse --filter=blob:none -- 'git@github.com:acme/web.git' '/src/work/web'
+git -C '/src/work/web' sparse-checkout set -- 'app' 'it'\''s'
+
+mkdir -p '/src/me'
+git clone --mirror -- 'https://github.com/me/old.git' '/src/me/old'
+`
+	if got := buf.String(); got != want {
+		t.Errorf("writeExportScript() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteExportCSV(t *testing.T) {
+	repos := []config.RepoWithPath{
+		{Repo: config.Repo{URL: "git@github.com:acme/api.git"}, Path: "work", Name: "api", FullPath: "/src/work/api"},
+		{Repo: config.Repo{URL: "https://github.com/me/a,b.git"}, Path: "me", Name: "a,b", FullPath: "/src/me/a,b"},
+	}
+
+	var buf bytes.Buffer
+	if err := writeExportCSV(&buf, repos); err != nil {
+		t.Fatal(err)
+	}
+	want := "path,url,fullpath\nwork.api,git@github.com:acme/api.git,/src/work/api\n\"me.a,b\",\"https://github.com/me/a,b.git\",\"/src/me/a,b\"\n"
+	if got := buf.String(); got != want {
+		t.Errorf("writeExportCSV() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,269 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oschrenk/arbol/internal/config"
+	"github.com/oschrenk/arbol/internal/git"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneFlag bool
+	fetchJobs int
+)
+
+var fetchCmd = &cobra.Command{
+	Use:   "fetch [path...]",
+	Short: "Fetch existing repositories",
+	Long: `Fetch all remotes and tags of the repositories that are already cloned.
+
+Unlike 'arbol sync --fetch', this never clones: repos that aren't on disk
+yet are skipped, so it's safe to run from cron while the config gains new
+entries.
+
+Without a path argument, fetches all repos in the account.
+With paths, fetches only repos under any of them. Paths may be globs, and
+--exclude skips repos as with sync.
+
+Up to --jobs repos (default 8, or jobs in [settings]) are fetched at once;
+each repo's lines are printed together once its fetch is done.
+
+Use --prune to also delete remote-tracking branches that are gone on the
+remote. Failed fetches are retried on network errors (--retries), and each
+fetch is bounded by --timeout. Use --log to record each fetch in the history
+log, as with sync, and --quiet (-q, -qq) to print less.
+
+Examples:
+  arbol fetch                   # fetch all cloned repos
+  arbol fetch work              # fetch repos under work
+  arbol fetch --prune           # also drop stale remote-tracking branches`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if fetchJobs < 1 {
+			return fmt.Errorf("--jobs must be at least 1")
+		}
+		git.SetMaxConcurrency(fetchJobs)
+
+		account, accountName, err := getAccount()
+		if err != nil {
+			return err
+		}
+
+		repos, filters, err := selectRepos(account, accountName, args)
+		if err != nil {
+			return err
+		}
+		if len(repos) == 0 {
+			if selection := describeSelection(filters); selection != "" {
+				fmt.Printf("No repos found %s in account '%s'\n", selection, accountName)
+			} else {
+				fmt.Printf("No repos configured in account '%s'\n", accountName)
+			}
+			return nil
+		}
+
+		history, err := openHistory("fetch", accountName)
+		if err != nil {
+			return err
+		}
+		defer history.Close()
+
+		ctx := cmd.Context()
+		var fetched, skipped, failed int
+		var mu sync.Mutex
+		forEachRepo(ctx, repos, fetchJobs, func(repo config.RepoWithPath) {
+			report := fetchOne(ctx, repo)
+
+			// One repo's output at a time, so repos fetched at once don't
+			// interleave
+			mu.Lock()
+			defer mu.Unlock()
+			if report.attempted {
+				history.record(report.displayPath, "fetch", report.start, report.retries, report.err)
+			}
+			printStep("%s", report.steps.String())
+			if report.problem != "" {
+				printProblem("%s", report.problem)
+			}
+			switch report.outcome {
+			case fetchFetched:
+				fetched++
+			case fetchSkipped:
+				skipped++
+			case fetchFailed:
+				failed++
+			}
+		})
+
+		var summary []string
+		if fetched > 0 {
+			summary = append(summary, fmt.Sprintf("%d fetched", fetched))
+		}
+		if skipped > 0 {
+			summary = append(summary, fmt.Sprintf("%d skipped (not cloned)", skipped))
+		}
+		if failed > 0 {
+			summary = append(summary, fmt.Sprintf("%d failed", failed))
+		}
+		if len(summary) == 0 {
+			summary = append(summary, "nothing to do")
+		}
+		if ctx.Err() != nil {
+			fmt.Printf("\nInterrupted, %d fetched so far\n", fetched)
+			fmt.Printf("Summary: %s\n", strings.Join(summary, ", "))
+			return errInterrupted
+		}
+		printSummary(strings.Join(summary, ", "), failed > 0)
+		if failed > 0 {
+			return fmt.Errorf("%s failed to fetch", pluralize(failed, "repo", "repos"))
+		}
+		return nil
+	},
+	ValidArgsFunction: completeRepoPath,
+}
+
+func init() {
+	fetchCmd.Flags().BoolVar(&pruneFlag, "prune", false, "Delete remote-tracking branches that no longer exist on the remote")
+	fetchCmd.Flags().IntVarP(&fetchJobs, "jobs", "j", 8, "Fetch up to N repos at once")
+	fetchCmd.Flags().IntVar(&retriesFlag, "retries", 2, "Retry fetches that fail with a network error up to N times")
+	fetchCmd.Flags().CountVarP(&quietFlag, "quiet", "q", "Only print errors and the summary; -qq also drops the summary on success")
+	fetchCmd.Flags().BoolVar(&logFlag, "log", false, "Append what was done to the history log")
+	fetchCmd.Flags().StringArrayVar(&excludeFlags, "exclude", nil, "Skip repos matching this path or glob (repeatable)")
+	fetchCmd.Flags().StringArrayVar(&tagFlags, "tag", nil, "Only repos with this tag (repeatable, all must match)")
+	fetchCmd.Flags().BoolVar(&tagAnyFlag, "tag-any", false, "With several --tag, select repos with any of them")
+	fetchCmd.Flags().BoolVar(&includeDisabledFlag, "include-disabled", false, "Also select repos with enabled = false")
+	rootCmd.AddCommand(fetchCmd)
+}
+
+// Outcomes of fetching one repo
+const (
+	fetchFetched = iota
+	fetchSkipped
+	fetchFailed
+	fetchAborted
+)
+
+// fetchReport is what fetching one repo printed and how it ended, collected
+// so it can be printed in one piece
+type fetchReport struct {
+	displayPath string
+	outcome     int
+	steps       lockedBuffer // per-repo lines and git's output, left out with --quiet
+	problem     string       // error or abort line, always printed
+	attempted   bool         // a fetch was run, to record in the history log
+	start       time.Time
+	retries     int
+	err         error
+}
+
+// fetchOne fetches repo if it's cloned, collecting its output
+func fetchOne(ctx context.Context, repo config.RepoWithPath) *fetchReport {
+	report := &fetchReport{displayPath: repo.Path + "." + repo.Name}
+	if ctx.Err() != nil {
+		report.outcome = fetchAborted
+		return report
+	}
+	if !git.Exists(repo.FullPath) {
+		fmt.Fprintf(&report.steps, "  skip  %s (not cloned)\n", report.displayPath)
+		report.outcome = fetchSkipped
+		return report
+	}
+
+	fmt.Fprintf(&report.steps, "  fetch %s\n", report.displayPath)
+	var out io.Writer
+	if quietFlag == 0 {
+		out = &report.steps
+	}
+	report.attempted, report.start = true, time.Now()
+	step := func(format string, args ...any) { fmt.Fprintf(&report.steps, format, args...) }
+	report.retries, report.err = withRetriesTo(ctx, report.displayPath, step, func(ctx context.Context) error {
+		return fetchRepoTo(ctx, repo, pruneFlag, out, out)
+	})
+	switch {
+	case ctx.Err() != nil:
+		report.err = ctx.Err()
+		report.problem = fmt.Sprintf("  abort %s (interrupted)\n", report.displayPath)
+		report.outcome = fetchAborted
+	case report.err != nil:
+		report.problem = fmt.Sprintf("  error %s: %v%s\n", report.displayPath, report.err, retriesSuffix(report.retries))
+		report.outcome = fetchFailed
+	default:
+		report.outcome = fetchFetched
+	}
+	return report
+}
+
+// forEachRepo calls fn for each of repos, jobs at a time, and returns once
+// all calls have. Repos not started when ctx is done are left out.
+func forEachRepo(ctx context.Context, repos []config.RepoWithPath, jobs int, fn func(config.RepoWithPath)) {
+	queue := make(chan config.RepoWithPath)
+	var wg sync.WaitGroup
+	for range max(1, min(jobs, len(repos))) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for repo := range queue {
+				fn(repo)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(queue)
+	for _, repo := range repos {
+		if ctx.Err() != nil {
+			return
+		}
+		select {
+		case queue <- repo:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// lockedBuffer is a bytes.Buffer safe for concurrent writes, as git's
+// stdout and stderr are copied into it at the same time
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// fetchRepo fetches repo, quietly or with git's output on stdout and stderr:
+// git remote update for mirrors, git fetch otherwise
+func fetchRepo(ctx context.Context, repo config.RepoWithPath, prune, quiet bool) error {
+	if quiet {
+		return fetchRepoTo(ctx, repo, prune, nil, nil)
+	}
+	return fetchRepoTo(ctx, repo, prune, os.Stdout, os.Stderr)
+}
+
+// fetchRepoTo is like fetchRepo with git's output going to stdout and
+// stderr, quietly when both are nil
+func fetchRepoTo(ctx context.Context, repo config.RepoWithPath, prune bool, stdout, stderr io.Writer) error {
+	if repo.Repo.Mirror {
+		return git.RemoteUpdateContext(ctx, repo.FullPath, prune, stdout, stderr)
+	}
+	if stdout == nil && stderr == nil {
+		return git.FetchQuietContext(ctx, repo.FullPath, prune)
+	}
+	return git.FetchContext(ctx, repo.FullPath, prune, stdout, stderr)
+}
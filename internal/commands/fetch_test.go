@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oschrenk/arbol/internal/config"
+)
+
+func TestForEachRepo(t *testing.T) {
+	var repos []config.RepoWithPath
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		repos = append(repos, config.RepoWithPath{Path: "work", Name: name})
+	}
+
+	var mu sync.Mutex
+	running, most := 0, 0
+	seen := make(map[string]bool)
+	forEachRepo(context.Background(), repos, 2, func(repo config.RepoWithPath) {
+		mu.Lock()
+		running++
+		most = max(most, running)
+		seen[repo.Name] = true
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+	})
+	if len(seen) != len(repos) || most > 2 {
+		t.Errorf("saw %d repos with up to %d at once, want %d with at most 2", len(seen), most, len(repos))
+	}
+
+	// Nothing starts once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	forEachRepo(ctx, repos, 1, func(config.RepoWithPath) { calls++ })
+	if calls != 0 {
+		t.Errorf("%d repos started after cancel", calls)
+	}
+}
+
+func TestFetchOneNotCloned(t *testing.T) {
+	repo := config.RepoWithPath{Path: "work", Name: "api", FullPath: filepath.Join(t.TempDir(), "api")}
+	report := fetchOne(context.Background(), repo)
+	if report.outcome != fetchSkipped || report.attempted || report.problem != "" {
+		t.Errorf("fetchOne() = %+v, want skipped without a fetch", report)
+	}
+	if got, want := report.steps.String(), "  skip  work.api (not cloned)\n"; got != want {
+		t.Errorf("steps = %q, want %q", got, want)
+	}
+}
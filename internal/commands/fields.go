@@ -0,0 +1,287 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oschrenk/arbol/internal/config"
+	"github.com/oschrenk/arbol/internal/git"
+)
+
+// statusField is a column of the plain status table. cell renders it for a
+// repo whose status was read; for repos that aren't cloned or couldn't be
+// read the table shows a placeholder instead, unless anyState is set, in
+// which case cell is called for every repo. streamWidth is the column's
+// width in a --stream table, which can't be measured up front.
+type statusField struct {
+	name        string
+	header      string
+	anyState    bool
+	cell        func(state repoState) string
+	streamWidth int
+}
+
+// statusFields lists every field --fields accepts, in the order they're
+// documented
+var statusFields = []statusField{
+	{name: "path", header: "PATH", anyState: true, cell: pathCell},
+	{name: "branch", header: "BRANCH", cell: branchCell},
+	{name: "work", header: "WORK", cell: workCell, streamWidth: 6},
+	{name: "remote", header: "REMOTE", cell: remoteCell, streamWidth: 7},
+	{name: "ahead", header: "AHEAD", cell: aheadCell},
+	{name: "behind", header: "BEHIND", cell: behindCell},
+	{name: "age", header: "AGE", cell: ageCell, streamWidth: 4},
+	{name: "fetched", header: "FETCHED", cell: fetchedCell},
+	{name: "author", header: "AUTHOR", cell: authorCell, streamWidth: 16},
+	{name: "subject", header: "SUBJECT", cell: subjectCell, streamWidth: subjectWidth},
+	{name: "comments", header: "COMMENTS", anyState: true, cell: commentsCell},
+}
+
+// Columns shown without --fields, and with --long
+const (
+	defaultFields = "path,branch,work,remote,age,comments"
+	longFields    = "path,branch,work,remote,age,author,subject,comments"
+)
+
+// parseFields resolves a comma-separated --fields list against statusFields
+func parseFields(spec string) ([]statusField, error) {
+	var fields []statusField
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		field, ok := lookupField(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown field '%s' (valid: %s)", name, strings.Join(fieldNames(), ", "))
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields given (valid: %s)", strings.Join(fieldNames(), ", "))
+	}
+	return fields, nil
+}
+
+func lookupField(name string) (statusField, bool) {
+	for _, field := range statusFields {
+		if field.name == name {
+			return field, true
+		}
+	}
+	return statusField{}, false
+}
+
+func fieldNames() []string {
+	names := make([]string, len(statusFields))
+	for i, field := range statusFields {
+		names[i] = field.name
+	}
+	return names
+}
+
+// headerRow returns the table header for fields
+func headerRow(fields []statusField) []string {
+	row := make([]string, len(fields))
+	for i, field := range fields {
+		row[i] = field.header
+	}
+	return row
+}
+
+// statusRow builds one table row for fields
+func statusRow(state repoState, fields []statusField) []string {
+	row := make([]string, len(fields))
+	for i, field := range fields {
+		switch {
+		case field.anyState:
+			row[i] = field.cell(state)
+		case !state.Cloned:
+			row[i] = colorize(colorGray, glyphs.none)
+		case state.Err != nil:
+			row[i] = colorize(colorGray, "?")
+		default:
+			row[i] = field.cell(state)
+		}
+	}
+	return row
+}
+
+func pathCell(state repoState) string {
+	return truncate(displayedPath(state.Repo.Path+"."+state.Repo.Name, state.Repo.FullPath), pathWidth)
+}
+
+func branchCell(state repoState) string {
+	branch := truncate(state.Status.Branch, branchWidth)
+	if state.Status.IsDetached {
+		return colorize(colorCyan, branch)
+	}
+	return branch
+}
+
+func workCell(state repoState) string {
+	if state.Status.IsBare {
+		if state.Repo.Repo.Mirror {
+			return colorize(colorCyan, "mirror")
+		}
+		return colorize(colorCyan, "bare")
+	}
+	if state.Status.IsDirty {
+		return colorize(colorYellow, fmt.Sprintf("%s %d", glyphs.dirty, state.Status.DirtyFiles))
+	}
+	return colorize(colorGreen, glyphs.clean)
+}
+
+func remoteCell(state repoState) string {
+	text, color, _ := remoteSummary(state.Status)
+	return colorize(color, text)
+}
+
+func aheadCell(state repoState) string {
+	return countCell(state.Status, state.Status.Ahead, colorYellow)
+}
+
+func behindCell(state repoState) string {
+	return countCell(state.Status, state.Status.Behind, colorRed)
+}
+
+// countCell renders an ahead or behind count, glyphs.none when there's nothing to
+// compare against
+func countCell(status *git.RepoStatus, n int, color string) string {
+	if status.IsDetached || status.IsBare || status.NoTracking || status.RemoteSkipped {
+		return colorize(colorGray, glyphs.none)
+	}
+	if n == 0 {
+		return colorize(colorGreen, "0")
+	}
+	return colorize(color, fmt.Sprint(n))
+}
+
+func ageCell(state repoState) string {
+	if state.Status.LastCommitTime.IsZero() {
+		return colorize(colorGray, "?")
+	}
+	return colorize(colorGray, formatRelativeTime(state.Status.LastCommitTime))
+}
+
+func fetchedCell(state repoState) string {
+	if state.Status.LastFetch.IsZero() {
+		return colorize(colorGray, "never")
+	}
+	color := colorGray
+	if fetchStale(state.Status) {
+		color = colorYellow
+	}
+	return colorize(color, formatRelativeTime(state.Status.LastFetch))
+}
+
+func authorCell(state repoState) string {
+	return state.Status.Author
+}
+
+func subjectCell(state repoState) string {
+	return truncate(state.Status.Subject, subjectWidth)
+}
+
+func commentsCell(state repoState) string {
+	if !state.Cloned {
+		return colorize(colorGray, "not cloned")
+	}
+	if errors.Is(state.Err, git.ErrDubiousOwnership) {
+		return colorize(colorRed, "dubious ownership") + colorize(colorGray, ", run: arbol trust "+state.Repo.Path+"."+state.Repo.Name)
+	}
+	if state.Err != nil {
+		return colorize(colorGray, state.Err.Error())
+	}
+
+	status := state.Status
+	var comments []string
+	if state.Repo.Repo.Mirror && !status.IsBare {
+		// Cloned before mirror = true was set; only a fresh clone converts it
+		comments = append(comments, "not a mirror, has a working tree")
+	}
+	if status.IsDirty {
+		comments = append(comments, pluralize(status.DirtyFiles, "dirty file", "dirty files"))
+	}
+	if _, _, comment := remoteSummary(status); comment != "" {
+		comments = append(comments, comment)
+	}
+	if remoteMismatch(state.Repo, status) {
+		comments = append(comments, "remote mismatch")
+	}
+	if state.FetchErr != nil {
+		comments = append(comments, "fetch failed")
+	} else if fetchStale(status) {
+		comments = append(comments, fmt.Sprintf("fetched %s ago, run sync --fetch", formatRelativeTime(status.LastFetch)))
+	}
+
+	comment := colorize(colorGray, strings.Join(comments, ", "))
+
+	// An interrupted rebase/merge leads the comments in red so it can't be
+	// missed among the routine ones
+	if status.Operation != "" {
+		operation := colorize(colorRed, strings.ToUpper(status.Operation)+" in progress")
+		if len(comments) > 0 {
+			comment = operation + colorize(colorGray, ", ") + comment
+		} else {
+			comment = operation
+		}
+	}
+	return comment
+}
+
+// staleFetchAge is how old the last fetch may get before ahead/behind counts
+// are flagged as possibly out of date
+const staleFetchAge = 7 * 24 * time.Hour
+
+// fetchStale reports whether the repo's ahead/behind counts rest on a fetch
+// older than staleFetchAge. Repos never fetched since cloning have no
+// FETCH_HEAD and aren't flagged.
+func fetchStale(status *git.RepoStatus) bool {
+	return !status.IsDetached && !status.LastFetch.IsZero() && time.Since(status.LastFetch) > staleFetchAge
+}
+
+// remoteSummary describes where the branch stands against its remote: the
+// REMOTE cell text and color, and the matching comment. Comments name the
+// remote when it isn't origin, so the counts aren't mistaken for a
+// comparison against origin.
+func remoteSummary(status *git.RepoStatus) (text, color, comment string) {
+	customRemote := status.Remote != config.DefaultRemote
+
+	switch {
+	case status.IsDetached:
+		return glyphs.clean, colorGreen, "detached HEAD"
+	case status.IsBare, status.RemoteSkipped:
+		return glyphs.none, colorGray, ""
+	case status.NoTracking:
+		if customRemote {
+			comment = fmt.Sprintf("no tracking branch on %s", status.Remote)
+		} else {
+			comment = "no tracking branch"
+		}
+		return glyphs.ahead + "?", colorYellow, comment
+	case status.Ahead > 0 && status.Behind > 0:
+		if customRemote {
+			comment = fmt.Sprintf("diverged from %s", status.Remote)
+		} else {
+			comment = "diverged"
+		}
+		return fmt.Sprintf("%s%d %s%d", glyphs.behind, status.Behind, glyphs.ahead, status.Ahead), colorMagenta, comment
+	case status.Behind > 0:
+		return fmt.Sprintf("%s%d", glyphs.behind, status.Behind), colorRed,
+			pluralize(status.Behind, "commit", "commits") + " behind " + status.Remote
+	case status.Ahead > 0:
+		if customRemote {
+			comment = pluralize(status.Ahead, "commit", "commits") + " ahead of " + status.Remote
+		} else {
+			comment = pluralize(status.Ahead, "unpushed commit", "unpushed commits")
+		}
+		return fmt.Sprintf("%s%d", glyphs.ahead, status.Ahead), colorYellow, comment
+	}
+	if customRemote {
+		comment = fmt.Sprintf("in sync with %s", status.Remote)
+	}
+	return glyphs.clean, colorGreen, comment
+}
@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oschrenk/arbol/internal/config"
+	"github.com/oschrenk/arbol/internal/git"
+)
+
+func TestParseFields(t *testing.T) {
+	fields, err := parseFields("path, Branch,ahead,,behind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"PATH", "BRANCH", "AHEAD", "BEHIND"}
+	if got := headerRow(fields); !reflect.DeepEqual(got, want) {
+		t.Errorf("headerRow() = %v, want %v", got, want)
+	}
+
+	_, err = parseFields("path,nope")
+	if err == nil || !strings.Contains(err.Error(), "nope") || !strings.Contains(err.Error(), "comments") {
+		t.Errorf("parseFields(unknown) error = %v, want one naming the field and the valid options", err)
+	}
+	if _, err := parseFields(" , "); err == nil {
+		t.Error("parseFields(empty) succeeded, want error")
+	}
+	for _, spec := range []string{defaultFields, longFields} {
+		if _, err := parseFields(spec); err != nil {
+			t.Errorf("parseFields(%q): %v", spec, err)
+		}
+	}
+}
+
+func TestStatusRow(t *testing.T) {
+	prev := noColor
+	noColor = true
+	defer func() { noColor = prev }()
+
+	fields, err := parseFields("path,branch,ahead,behind,remote,comments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := config.RepoWithPath{Path: "work", Name: "api"}
+
+	cases := []struct {
+		name  string
+		state repoState
+		want  []string
+	}{
+		{
+			"behind upstream",
+			repoState{Repo: repo, Cloned: true, Status: &git.RepoStatus{Branch: "main", Remote: "upstream", Behind: 2}},
+			[]string{"work.api", "main", "0", "2", "↓2", "2 commits behind upstream"},
+		},
+		{
+			"no tracking",
+			repoState{Repo: repo, Cloned: true, Status: &git.RepoStatus{Branch: "wip", Remote: "origin", NoTracking: true, IsDirty: true, DirtyFiles: 3}},
+			[]string{"work.api", "wip", "—", "—", "↑?", "3 dirty files, no tracking branch"},
+		},
+		{
+			"not cloned",
+			repoState{Repo: repo},
+			[]string{"work.api", "—", "—", "—", "—", "not cloned"},
+		},
+	}
+	for _, c := range cases {
+		if got := statusRow(c.state, fields); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: statusRow() = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestFetchStale(t *testing.T) {
+	cases := []struct {
+		name   string
+		status git.RepoStatus
+		want   bool
+	}{
+		{"never fetched", git.RepoStatus{}, false},
+		{"fetched today", git.RepoStatus{LastFetch: time.Now().Add(-time.Hour)}, false},
+		{"fetched last month", git.RepoStatus{LastFetch: time.Now().Add(-30 * 24 * time.Hour)}, true},
+		{"detached", git.RepoStatus{IsDetached: true, LastFetch: time.Now().Add(-30 * 24 * time.Hour)}, false},
+	}
+	for _, c := range cases {
+		if got := fetchStale(&c.status); got != c.want {
+			t.Errorf("%s: fetchStale() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestDisplayedPath(t *testing.T) {
+	prev := displayFlag
+	defer func() { displayFlag = prev }()
+
+	state := repoState{Repo: config.RepoWithPath{Path: "work.backend", Name: "api", FullPath: "/home/me/Projects/work/backend/api"}}
+	want := map[string]string{
+		displayDotted:  "work.backend.api",
+		displayRelpath: filepath.FromSlash("work/backend/api"),
+		displayAbspath: "/home/me/Projects/work/backend/api",
+	}
+	for display, path := range want {
+		displayFlag = display
+		if got := pathCell(state); got != path {
+			t.Errorf("--display %s: pathCell() = %q, want %q", display, got, path)
+		}
+	}
+}
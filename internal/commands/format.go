@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/oschrenk/arbol/internal/config"
+	"github.com/oschrenk/arbol/internal/git"
+)
+
+// templateRepo is what --format templates run against: the configured repo
+// (Path, Name, FullPath, Repo.URL) and its status (Branch, IsDirty, Ahead,
+// ...). Status fields are zero for repos that aren't cloned or couldn't be
+// read; Cloned and Error tell those apart.
+type templateRepo struct {
+	config.RepoWithPath
+	git.RepoStatus
+	ID     string
+	Cloned bool
+	Error  string
+}
+
+// templateFuncs are the helpers available in --format templates
+var templateFuncs = template.FuncMap{
+	// relTime formats a time like the AGE column ("3d"), "?" if unknown
+	"relTime": func(t time.Time) string {
+		if t.IsZero() {
+			return "?"
+		}
+		return formatRelativeTime(t)
+	},
+	// pad right-pads a value to a fixed width, for aligned columns
+	"pad": func(width int, v any) string {
+		return padRight(fmt.Sprint(v), width)
+	},
+}
+
+// parseFormat parses a --format template
+func parseFormat(format string) (*template.Template, error) {
+	tmpl, err := template.New("format").Funcs(templateFuncs).Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --format template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// newTemplateRepo builds the template data for a gathered repo state
+func newTemplateRepo(state repoState) templateRepo {
+	data := templateRepo{
+		RepoWithPath: state.Repo,
+		ID:           state.Repo.Path + "." + state.Repo.Name,
+		Cloned:       state.Cloned,
+	}
+	if state.Err != nil {
+		data.Error = state.Err.Error()
+	} else if state.Status != nil {
+		data.RepoStatus = *state.Status
+	}
+	return data
+}
+
+// writeFormatted executes tmpl once per repo, each on its own line
+func writeFormatted(w io.Writer, tmpl *template.Template, states []repoState) error {
+	for _, state := range states {
+		var line strings.Builder
+		if err := tmpl.Execute(&line, newTemplateRepo(state)); err != nil {
+			return fmt.Errorf("failed to format %s.%s: %w", state.Repo.Path, state.Repo.Name, err)
+		}
+		fmt.Fprintln(w, line.String())
+	}
+	return nil
+}
+
+// formatMarkdown is the --format value that prints a Markdown table
+const formatMarkdown = "markdown"
+
+// writeMarkdown writes states as a GitHub-flavored Markdown table with the
+// given columns, for pasting into issues or wikis. Cells are the table's,
+// without color; pipes in them are escaped.
+func writeMarkdown(w io.Writer, states []repoState, fields []statusField) {
+	writeMarkdownRow(w, headerRow(fields))
+	separator := make([]string, len(fields))
+	for i := range separator {
+		separator[i] = "---"
+	}
+	writeMarkdownRow(w, separator)
+	for _, state := range states {
+		writeMarkdownRow(w, statusRow(state, fields))
+	}
+}
+
+func writeMarkdownRow(w io.Writer, cells []string) {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		escaped[i] = strings.ReplaceAll(stripAnsi(cell), "|", `\|`)
+	}
+	fmt.Fprintf(w, "| %s |\n", strings.Join(escaped, " | "))
+}
@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oschrenk/arbol/internal/config"
+	"github.com/oschrenk/arbol/internal/git"
+)
+
+func TestWriteFormatted(t *testing.T) {
+	tmpl, err := parseFormat("{{.ID}} {{.Branch}} {{.Ahead}}/{{.Behind}} {{relTime .LastCommitTime}}{{if .Error}} {{.Error}}{{end}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	states := []repoState{
+		{
+			Repo:   config.RepoWithPath{Path: "work", Name: "api"},
+			Cloned: true,
+			Status: &git.RepoStatus{Branch: "main", Ahead: 2, Behind: 1, LastCommitTime: time.Now().Add(-3 * time.Hour)},
+		},
+		{Repo: config.RepoWithPath{Path: "work", Name: "web"}},
+		{Repo: config.RepoWithPath{Path: "work", Name: "old"}, Cloned: true, Err: errors.New("boom")},
+	}
+
+	var buf bytes.Buffer
+	if err := writeFormatted(&buf, tmpl, states); err != nil {
+		t.Fatal(err)
+	}
+	want := "work.api main 2/1 3h\nwork.web  0/0 ?\nwork.old  0/0 ? boom\n"
+	if got := buf.String(); got != want {
+		t.Errorf("writeFormatted() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParseFormatInvalid(t *testing.T) {
+	if _, err := parseFormat("{{.ID"); err == nil {
+		t.Error("parseFormat with unclosed action succeeded, want error")
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	prev := noColor
+	noColor = false
+	t.Setenv("CLICOLOR_FORCE", "1")
+	defer func() { noColor = prev }()
+
+	fields, err := parseFields("path,branch,remote,comments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := []repoState{
+		{
+			Repo:   config.RepoWithPath{Path: "work", Name: "api"},
+			Cloned: true,
+			Status: &git.RepoStatus{Branch: "fix|pipe", Remote: "origin", Behind: 2},
+		},
+		{Repo: config.RepoWithPath{Path: "work", Name: "web"}},
+	}
+
+	var out bytes.Buffer
+	writeMarkdown(&out, states, fields)
+	want := `| PATH | BRANCH | REMOTE | COMMENTS |
+| --- | --- | --- | --- |
+| work.api | fix\|pipe | ↓2 | 2 commits behind origin |
+| work.web | — | — | not cloned |
+`
+	if out.String() != want {
+		t.Errorf("writeMarkdown() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
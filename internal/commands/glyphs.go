@@ -0,0 +1,41 @@
+package commands
+
+import "strings"
+
+// asciiFlag forces ASCII status symbols
+var asciiFlag bool
+
+// glyphSet holds the symbols of the status table, so they switch together
+type glyphSet struct {
+	dirty    string // uncommitted changes, before the file count
+	clean    string // clean or in sync
+	ahead    string // before the commits ahead count
+	behind   string // before the commits behind count
+	none     string // a cell with nothing to show
+	ellipsis string // end of a truncated cell
+	changed  string // a --watch row that changed since the last redraw
+}
+
+var (
+	unicodeGlyphs = glyphSet{dirty: "●", clean: "✔", ahead: "↑", behind: "↓", none: "—", ellipsis: "…", changed: "»"}
+	asciiGlyphs   = glyphSet{dirty: "*", clean: "ok", ahead: "^", behind: "v", none: "-", ellipsis: "...", changed: ">"}
+)
+
+// glyphs is the set in use, picked by useASCII before rendering
+var glyphs = unicodeGlyphs
+
+// useASCII decides between the glyph sets: --ascii forces ASCII, as does a
+// locale that isn't UTF-8. The locale is the first of LC_ALL, LC_CTYPE, and
+// LANG that is set; with none set, Unicode is kept.
+func useASCII(asciiFlag bool, getenv func(string) string) bool {
+	if asciiFlag {
+		return true
+	}
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale := getenv(name); locale != "" {
+			locale = strings.ToLower(locale)
+			return !strings.Contains(locale, "utf-8") && !strings.Contains(locale, "utf8")
+		}
+	}
+	return false
+}
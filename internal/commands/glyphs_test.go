@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/oschrenk/arbol/internal/config"
+	"github.com/oschrenk/arbol/internal/git"
+)
+
+func TestUseASCII(t *testing.T) {
+	cases := []struct {
+		name  string
+		flag  bool
+		env   map[string]string
+		ascii bool
+	}{
+		{"no locale", false, nil, false},
+		{"utf-8", false, map[string]string{"LANG": "en_US.UTF-8"}, false},
+		{"utf8", false, map[string]string{"LANG": "de_DE.utf8"}, false},
+		{"C locale", false, map[string]string{"LANG": "C"}, true},
+		{"latin-1", false, map[string]string{"LANG": "en_US.ISO-8859-1"}, true},
+		{"LC_ALL wins", false, map[string]string{"LC_ALL": "C", "LANG": "en_US.UTF-8"}, true},
+		{"LC_CTYPE before LANG", false, map[string]string{"LC_CTYPE": "en_US.UTF-8", "LANG": "C"}, false},
+		{"flag", true, map[string]string{"LANG": "en_US.UTF-8"}, true},
+	}
+	for _, c := range cases {
+		if got := useASCII(c.flag, func(name string) string { return c.env[name] }); got != c.ascii {
+			t.Errorf("%s: useASCII() = %v, want %v", c.name, got, c.ascii)
+		}
+	}
+}
+
+func TestASCIIGlyphs(t *testing.T) {
+	prevGlyphs, prevColor := glyphs, noColor
+	glyphs, noColor = asciiGlyphs, true
+	defer func() { glyphs, noColor = prevGlyphs, prevColor }()
+
+	fields, err := parseFields("path,branch,work,ahead,remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := config.RepoWithPath{Path: "work", Name: "api"}
+	cases := []struct {
+		state repoState
+		want  []string
+	}{
+		{
+			repoState{Repo: repo, Cloned: true, Status: &git.RepoStatus{Branch: "main", Remote: "origin", IsDirty: true, DirtyFiles: 2, Ahead: 1, Behind: 3}},
+			[]string{"work.api", "main", "* 2", "1", "v3 ^1"},
+		},
+		{
+			repoState{Repo: repo, Cloned: true, Status: &git.RepoStatus{Branch: "wip", Remote: "origin", NoTracking: true}},
+			[]string{"work.api", "wip", "ok", "-", "^?"},
+		},
+	}
+	for _, c := range cases {
+		if got := statusRow(c.state, fields); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("statusRow() = %q, want %q", got, c.want)
+		}
+	}
+
+	for in, want := range map[string]string{"feature/login": "featu...", "main": "main"} {
+		if got := truncate(in, 8); got != want {
+			t.Errorf("truncate(%q, 8) = %q, want %q", in, got, want)
+		}
+	}
+	if got := truncate("feature", 2); got != "fe" {
+		t.Errorf("truncate to less than the ellipsis = %q, want %q", got, "fe")
+	}
+}
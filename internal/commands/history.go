@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/oschrenk/arbol/internal/config"
+)
+
+// logFlag turns on the history log for sync and fetch
+var logFlag bool
+
+// historyFile is the name of the history log next to the config file
+const historyFile = "history.log"
+
+// historyEntry is one line of the history log
+type historyEntry struct {
+	Time       string `json:"time"`
+	Command    string `json:"command"`
+	Account    string `json:"account"`
+	Repo       string `json:"repo"`
+	Action     string `json:"action"`
+	Result     string `json:"result"` // ok, failed, or interrupted
+	Error      string `json:"error,omitempty"`
+	Retries    int    `json:"retries,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// historyLog appends what a sync or fetch did to the history log, one JSON
+// object per line. A nil *historyLog records nothing, so callers don't need
+// to check whether logging is on.
+type historyLog struct {
+	file    *os.File
+	command string
+	account string
+	failed  bool // a write failed and was reported
+}
+
+// historyPath returns where the history log goes: log_file from [settings],
+// or history.log next to the config file
+func historyPath(settings config.Settings) string {
+	if settings.LogFile != "" {
+		return config.ExpandPath(settings.LogFile)
+	}
+	return filepath.Join(filepath.Dir(configPath()), historyFile)
+}
+
+// openHistory opens the history log for command in account, or returns nil
+// without --log (or log = true in [settings])
+func openHistory(command, account string) (*historyLog, error) {
+	if !logFlag {
+		return nil, nil
+	}
+	path := historyPath(cfg.Settings)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history log: %w", err)
+	}
+	return &historyLog{file: file, command: command, account: account}, nil
+}
+
+// record logs an action on repo that started at start and ended with err
+// after retries retries. A failed write is reported once on stderr and
+// doesn't stop the run.
+func (h *historyLog) record(repo, action string, start time.Time, retries int, err error) {
+	if h == nil {
+		return
+	}
+	entry := historyEntry{
+		Time:       start.Format(time.RFC3339),
+		Command:    h.command,
+		Account:    h.account,
+		Repo:       repo,
+		Action:     action,
+		Result:     "ok",
+		Retries:    retries,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		entry.Result = "interrupted"
+	case err != nil:
+		entry.Result = "failed"
+		entry.Error = err.Error()
+	}
+
+	line, _ := json.Marshal(entry)
+	if _, err := h.file.Write(append(line, '\n')); err != nil && !h.failed {
+		fmt.Fprintf(os.Stderr, "warning: failed to write history log: %v\n", err)
+		h.failed = true
+	}
+}
+
+// Close closes the log file
+func (h *historyLog) Close() error {
+	if h == nil {
+		return nil
+	}
+	return h.file.Close()
+}
@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oschrenk/arbol/internal/config"
+)
+
+func TestHistoryLog(t *testing.T) {
+	prevLog, prevCfg := logFlag, cfg
+	defer func() { logFlag, cfg = prevLog, prevCfg }()
+
+	path := filepath.Join(t.TempDir(), "logs", "arbol.log")
+	cfg = &config.Config{Settings: config.Settings{LogFile: path}}
+
+	logFlag = false
+	history, err := openHistory("sync", "work")
+	if err != nil || history != nil {
+		t.Fatalf("openHistory() without --log = %v, %v; want nil", history, err)
+	}
+	// A nil log records nothing
+	history.record("work.api", "clone", time.Now(), 0, nil)
+	if err := history.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	logFlag = true
+	history, err = openHistory("sync", "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	history.record("work.api", "clone", start, 0, nil)
+	history.record("work.web", "fetch", start, 2, errors.New("connection reset"))
+	history.record("work.cli", "clone", start, 0, context.Canceled)
+	if err := history.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d log lines, want 3:\n%s", len(lines), data)
+	}
+	var entries []historyEntry
+	for _, line := range lines {
+		var entry historyEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if e := entries[0]; e.Command != "sync" || e.Account != "work" || e.Repo != "work.api" || e.Action != "clone" || e.Result != "ok" {
+		t.Errorf("entry 0 = %+v", e)
+	}
+	if e := entries[1]; e.Result != "failed" || e.Error != "connection reset" || e.Retries != 2 {
+		t.Errorf("entry 1 = %+v", e)
+	}
+	if e := entries[2]; e.Result != "interrupted" || e.Error != "" {
+		t.Errorf("entry 2 = %+v", e)
+	}
+}
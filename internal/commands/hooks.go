@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// skipHooksFlag disables post_clone hooks
+var skipHooksFlag bool
+
+// runHooks runs each shell command in dir, in order, with output streamed to
+// out and any extra env added to the environment. It stops at the first
+// command that fails. Hooks aren't bounded by --timeout, since setup like
+// installing dependencies can take long, but stop when ctx is done.
+func runHooks(ctx context.Context, dir string, commands []string, env []string, out io.Writer) error {
+	for _, command := range commands {
+		cmd := shellCommand(ctx, command)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Stdout = out
+		cmd.Stderr = out
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("hook %q failed: %w", command, err)
+		}
+	}
+	return nil
+}
+
+// runQuietHooks is runHooks with output to stdout, or with --quiet or the
+// progress line held back and printed only if a command fails
+func runQuietHooks(ctx context.Context, dir string, commands []string, env []string) error {
+	if quietFlag == 0 && bar == nil {
+		return runHooks(ctx, dir, commands, env, os.Stdout)
+	}
+	var out bytes.Buffer
+	err := runHooks(ctx, dir, commands, env, &out)
+	if err != nil && out.Len() > 0 {
+		printProblem("%s", out.String())
+	}
+	return err
+}
+
+// shellCommand runs command through the platform's shell
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRunHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks are sh commands here")
+	}
+	dir := t.TempDir()
+	var out strings.Builder
+	err := runHooks(context.Background(), dir, []string{"pwd", "echo $ARBOL_TEST", "exit 3", "echo unreachable"}, []string{"ARBOL_TEST=set"}, &out)
+	if err == nil || !strings.Contains(err.Error(), `"exit 3"`) {
+		t.Errorf("runHooks() error = %v, want the failing command named", err)
+	}
+
+	resolved, _ := filepath.EvalSymlinks(dir)
+	want := resolved + "\nset\n"
+	if out.String() != want {
+		t.Errorf("hook output = %q, want %q", out.String(), want)
+	}
+}
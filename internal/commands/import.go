@@ -0,0 +1,411 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oschrenk/arbol/internal/config"
+	"github.com/oschrenk/arbol/internal/git"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importWrite bool
+	importDepth int
+	importFrom  string
+	importBase  string
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import [dir | .gitmodules]",
+	Short: "Generate config for repositories already on disk",
+	Long: `Scan a directory for git repositories and print a config for them.
+
+Each repo's path below dir becomes its place in the tree, and its origin URL
+becomes the repo URL: dir/work/backend/api turns into an entry for api under
+repos.work.backend. Repos sitting directly in a directory that also has
+subdirectories with repos are put under the "/" key. Repos without an origin
+remote, and repos directly in dir, are skipped with a warning.
+
+Without dir, the current directory is scanned, at most --depth levels deep.
+
+Use --from ghq to import a ghq root, where repos live at host/user/repo.
+Host names contain dots, which arbol paths can't, so the host becomes its
+name without the top-level domain: ~/ghq/github.com/user/repo turns into
+github.user.repo, i.e. ~/ghq/github/user/repo. Rename each host directory
+(mv ~/ghq/github.com ~/ghq/github) to keep your clones, or let arbol sync
+clone into the new layout. Other dots become dashes (gitlab.example.com ->
+gitlab-example).
+
+Use --from gitmodules with a .gitmodules file (or the directory holding one)
+to turn a superproject's submodules into independent clones: each module's
+path becomes its place in the tree, below the superproject's directory.
+Relative module URLs (../lib.git) are resolved like git does, against the
+superproject URL given with --base-url.
+
+Use --write to add the repos to the config file instead of printing them,
+to the account given with --account or else the one other commands would
+use (or "default", in a new config). Repos the account already has are left alone; an account that doesn't exist
+yet is created with dir as its root. The file is rewritten (comments are
+not kept) and the previous version saved next to it with a .bak suffix.
+
+Examples:
+  arbol import ~/Projects                    # print a config
+  arbol import ~/Projects > config.toml      # start a config from it
+  arbol import ~/Projects --write -a laptop  # merge into account laptop
+  arbol import --from ghq ~/ghq              # migrate from ghq
+  arbol import --from gitmodules app/.gitmodules --base-url git@github.com:org/app.git`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) > 0 {
+			dir = config.ExpandPath(args[0])
+		}
+		root, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(root)
+		if err != nil {
+			return fmt.Errorf("%s does not exist", dir)
+		}
+
+		// A .gitmodules file stands for the superproject directory
+		file := ""
+		if importFrom == "gitmodules" {
+			file = root
+			if info.IsDir() {
+				file = filepath.Join(root, ".gitmodules")
+			} else {
+				root = filepath.Dir(root)
+			}
+		} else if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+
+		var repos []importedRepo
+		switch importFrom {
+		case "":
+			repos, err = scanImport(root, importDepth)
+		case "ghq":
+			repos, err = scanGhq(root)
+		case "gitmodules":
+			repos, err = readGitmodules(file, importBase)
+		default:
+			return fmt.Errorf("unknown --from '%s' (valid: ghq, gitmodules)", importFrom)
+		}
+		if err != nil {
+			return err
+		}
+		return finishImport(root, repos)
+	},
+}
+
+func init() {
+	importCmd.Flags().BoolVar(&importWrite, "write", false, "Add the repos to the config file instead of printing them")
+	importCmd.Flags().IntVar(&importDepth, "depth", 4, "How many directory levels to search below dir")
+	importCmd.Flags().StringVar(&importFrom, "from", "", "What to import: ghq for a host/user/repo root, gitmodules for a .gitmodules file")
+	importCmd.Flags().StringVar(&importBase, "base-url", "", "Superproject URL that relative submodule URLs are resolved against (with --from gitmodules)")
+	importCmd.RegisterFlagCompletionFunc("from", cobra.FixedCompletions([]string{"ghq", "gitmodules"}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.AddCommand(importCmd)
+}
+
+// importedRepo is a repo found by import, placed in the tree relative to the
+// directory that was imported
+type importedRepo struct {
+	Path string // dotted container path, e.g. "work.backend"; empty for a repo directly in the imported directory
+	Repo config.Repo
+}
+
+// id returns the repo's dotted path below prefix (a dotted path ending in
+// "."), or "" if that leaves it without a container, which the tree can't
+// express
+func (r importedRepo) id(prefix string) (path, id string) {
+	path = strings.TrimSuffix(prefix+r.Path, ".")
+	if path == "" {
+		return "", ""
+	}
+	return path, path + "." + r.name()
+}
+
+// name returns the repo's directory name
+func (r importedRepo) name() string {
+	if r.Repo.Name != "" {
+		return r.Repo.Name
+	}
+	return config.RepoName(r.Repo.URL)
+}
+
+// skipRootRepo warns about a repo that would sit directly in the account root
+func skipRootRepo(repo importedRepo, root string) {
+	fmt.Fprintf(os.Stderr, "  skip  %s (directly in %s, move it into a subdirectory)\n", repo.name(), root)
+}
+
+// scanImport finds the repos below root and reads their origin URLs
+func scanImport(root string, depth int) ([]importedRepo, error) {
+	found, err := git.FindRepos(root, depth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+
+	var repos []importedRepo
+	for _, dir := range found {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			continue
+		}
+		segments := strings.Split(rel, string(filepath.Separator))
+		if dotted := dottedDir(segments); dotted != "" {
+			skipDottedDir(rel, dotted)
+			continue
+		}
+		url, err := git.RemoteURL(dir, git.CloneRemote)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  skip  %s (no %s remote)\n", rel, git.CloneRemote)
+			continue
+		}
+		repos = append(repos, newImportedRepo(segments, url))
+	}
+	return repos, nil
+}
+
+// scanGhq finds the repos of a ghq root, laid out as host/user/repo
+func scanGhq(root string) ([]importedRepo, error) {
+	found, err := git.FindRepos(root, 3)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+
+	var repos []importedRepo
+	for _, dir := range found {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			continue
+		}
+		segments := strings.Split(rel, string(filepath.Separator))
+		if len(segments) != 3 {
+			fmt.Fprintf(os.Stderr, "  skip  %s (not host/user/repo)\n", rel)
+			continue
+		}
+		host, user, name := segments[0], segments[1], segments[2]
+		if dotted := dottedDir([]string{user, name}); dotted != "" {
+			skipDottedDir(rel, dotted)
+			continue
+		}
+
+		// ghq clones have origin set, but the layout names the repo too
+		url, err := git.RemoteURL(dir, git.CloneRemote)
+		if err != nil {
+			url = "https://" + host + "/" + user + "/" + name
+		}
+		repos = append(repos, newImportedRepo([]string{ghqHostSegment(host), user, name}, url))
+	}
+	return repos, nil
+}
+
+// readGitmodules turns the entries of a .gitmodules file into repos placed
+// by their module path, resolving relative URLs against baseURL
+func readGitmodules(file, baseURL string) ([]importedRepo, error) {
+	if _, err := os.Stat(file); err != nil {
+		return nil, fmt.Errorf("no .gitmodules at %s", file)
+	}
+	modules, err := git.Submodules(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var repos []importedRepo
+	for _, module := range modules {
+		if module.Path == "" || module.URL == "" {
+			fmt.Fprintf(os.Stderr, "  skip  %s (missing path or url)\n", module.Name)
+			continue
+		}
+		url := module.URL
+		if strings.HasPrefix(url, "./") || strings.HasPrefix(url, "../") {
+			if baseURL == "" {
+				fmt.Fprintf(os.Stderr, "  skip  %s (relative url %s, pass --base-url)\n", module.Path, url)
+				continue
+			}
+			url = git.ResolveSubmoduleURL(baseURL, url)
+		}
+		segments := strings.Split(filepath.Clean(filepath.FromSlash(module.Path)), string(filepath.Separator))
+		if dotted := dottedDir(segments); dotted != "" {
+			skipDottedDir(module.Path, dotted)
+			continue
+		}
+		repos = append(repos, newImportedRepo(segments, url))
+	}
+	return repos, nil
+}
+
+// ghqHostSegment turns a host directory of a ghq root into a path segment,
+// which can't contain dots: the top-level domain is dropped and remaining
+// dots become dashes, so github.com -> github and gitlab.example.com ->
+// gitlab-example
+func ghqHostSegment(host string) string {
+	if i := strings.LastIndex(host, "."); i > 0 {
+		host = host[:i]
+	}
+	return strings.ReplaceAll(host, ".", "-")
+}
+
+// dottedDir returns the first directory of a repo at segments (the repo's
+// own directory excluded) whose name has a dot, or "" if none has. Paths
+// join directories with dots, so acme.com/site would come back from the
+// config as acme/com/site.
+func dottedDir(segments []string) string {
+	for _, segment := range segments[:len(segments)-1] {
+		if strings.Contains(segment, ".") {
+			return segment
+		}
+	}
+	return ""
+}
+
+// skipDottedDir warns about the repo at rel, left out because its directory
+// dotted has a dot
+func skipDottedDir(rel, dotted string) {
+	fmt.Fprintf(os.Stderr, "  skip  %s (directory %s has a dot, which config paths can't hold; rename it)\n", rel, dotted)
+}
+
+// newImportedRepo places a repo whose directory is segments (relative to the
+// import root) in the tree, recording its name only when it differs from
+// the one derived from url
+func newImportedRepo(segments []string, url string) importedRepo {
+	name := segments[len(segments)-1]
+	repo := config.Repo{URL: url}
+	if config.RepoName(url) != name {
+		repo.Name = name
+	}
+	return importedRepo{Path: strings.Join(segments[:len(segments)-1], "."), Repo: repo}
+}
+
+// finishImport prints the imported repos as a config for an account rooted
+// at root, or with --write merges them into the config file
+func finishImport(root string, repos []importedRepo) error {
+	if len(repos) == 0 {
+		fmt.Fprintf(os.Stderr, "No repos found in %s\n", root)
+		return nil
+	}
+
+	if !importWrite {
+		accountName := accountFlag
+		if accountName == "" {
+			accountName = "default"
+		}
+		return config.EncodeAccount(os.Stdout, accountName, importedAccount(root, repos))
+	}
+	return writeImport(root, accountFlag, repos)
+}
+
+// importedAccount returns a default account rooted at root holding repos
+func importedAccount(root string, repos []importedRepo) *config.Account {
+	account := &config.Account{Default: true, Root: tildePath(root), Repos: make(map[string][]config.Repo)}
+	for _, repo := range repos {
+		path, _ := repo.id("")
+		if path == "" {
+			skipRootRepo(repo, root)
+			continue
+		}
+		account.Repos[path] = append(account.Repos[path], repo.Repo)
+	}
+	return account
+}
+
+// writeImport merges repos into account accountName of the config file,
+// creating it if needed. Without a name, the account is the one other
+// commands would use (see resolveAccount), or "default" in a new config.
+func writeImport(root, accountName string, repos []importedRepo) error {
+	path := configPath()
+	c, err := config.LoadFromPath(path)
+	exists := err == nil
+	if err != nil {
+		if _, statErr := os.Stat(path); !errors.Is(statErr, os.ErrNotExist) {
+			return err
+		}
+		c = &config.Config{Accounts: make(map[string]*config.Account)}
+	}
+	if accountName == "" {
+		accountName = "default"
+		if len(c.Accounts) > 0 {
+			if _, accountName, _, err = resolveAccount(c); err != nil {
+				return err
+			}
+		}
+	}
+
+	account, ok := c.Accounts[accountName]
+	if !ok {
+		account = &config.Account{Default: len(c.Accounts) == 0, Root: tildePath(root), Repos: make(map[string][]config.Repo)}
+		c.Accounts[accountName] = account
+	}
+
+	// The repos are placed relative to root, which may sit below the
+	// account's root
+	rel, err := filepath.Rel(config.ExpandPath(account.Root), root)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is outside the root %s of account '%s'", root, account.Root, accountName)
+	}
+	prefix := ""
+	if rel != "." {
+		prefix = strings.ReplaceAll(rel, string(filepath.Separator), ".") + "."
+	}
+
+	known := make(map[string]bool)
+	for _, repo := range account.GetAllRepos("") {
+		known[repo.Path+"."+repo.Name] = true
+	}
+
+	added := 0
+	for _, repo := range repos {
+		repoPath, id := repo.id(prefix)
+		if repoPath == "" {
+			skipRootRepo(repo, root)
+			continue
+		}
+		if known[id] {
+			fmt.Printf("  skip  %s (already in config)\n", id)
+			continue
+		}
+		if err := account.AddRepo(repoPath, repo.Repo); err != nil {
+			fmt.Printf("  skip  %s (%v)\n", id, err)
+			continue
+		}
+		known[id] = true
+		fmt.Printf("  add   %s\n", id)
+		added++
+	}
+
+	if added == 0 {
+		fmt.Println("\nNothing to add")
+		return nil
+	}
+	if err := saveConfig(c, path, exists); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nAdded %s to account '%s' in %s\n", pluralize(added, "repo", "repos"), accountName, path)
+	if exists {
+		fmt.Printf("Previous config saved to %s.bak\n", path)
+	}
+	return nil
+}
+
+// tildePath abbreviates the home directory in path to ~, the form configs
+// are usually written in
+func tildePath(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return path
+	}
+	if path == home {
+		return "~"
+	}
+	if strings.HasPrefix(path, home+string(filepath.Separator)) {
+		return "~" + path[len(home):]
+	}
+	return path
+}
@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oschrenk/arbol/internal/config"
+)
+
+func TestImportedRepoID(t *testing.T) {
+	cases := []struct {
+		segments []string
+		url      string
+		prefix   string
+		wantPath string
+		wantID   string
+		wantName string
+	}{
+		{[]string{"work", "backend", "api"}, "git@github.com:co/api.git", "", "work.backend", "work.backend.api", ""},
+		{[]string{"work", "worker-svc"}, "git@github.com:co/worker.git", "", "work", "work.worker-svc", "worker-svc"},
+		{[]string{"api"}, "git@github.com:co/api.git", "work.", "work", "work.api", ""},
+		{[]string{"api"}, "git@github.com:co/api.git", "", "", "", ""},
+	}
+	for _, c := range cases {
+		repo := newImportedRepo(c.segments, c.url)
+		if repo.Repo.Name != c.wantName {
+			t.Errorf("newImportedRepo(%v).Repo.Name = %q, want %q", c.segments, repo.Repo.Name, c.wantName)
+		}
+		path, id := repo.id(c.prefix)
+		if path != c.wantPath || id != c.wantID {
+			t.Errorf("id(%q) for %v = %q, %q; want %q, %q", c.prefix, c.segments, path, id, c.wantPath, c.wantID)
+		}
+	}
+}
+
+func TestDottedDir(t *testing.T) {
+	cases := []struct {
+		segments []string
+		want     string
+	}{
+		{[]string{"work", "backend", "api"}, ""},
+		{[]string{"acme.com", "site"}, "acme.com"},
+		{[]string{"work", "v1.2", "api"}, "v1.2"},
+		// The repo's own directory is its name, not part of the path
+		{[]string{"web", "example.com"}, ""},
+	}
+	for _, c := range cases {
+		if got := dottedDir(c.segments); got != c.want {
+			t.Errorf("dottedDir(%v) = %q, want %q", c.segments, got, c.want)
+		}
+	}
+}
+
+func TestGhqHostSegment(t *testing.T) {
+	cases := map[string]string{
+		"github.com":         "github",
+		"gitlab.example.com": "gitlab-example",
+		"localhost":          "localhost",
+	}
+	for host, want := range cases {
+		if got := ghqHostSegment(host); got != want {
+			t.Errorf("ghqHostSegment(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestWriteImportAccount(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "src")
+	oldConfig := configFlag
+	t.Cleanup(func() { configFlag = oldConfig })
+	t.Setenv(accountEnv, "")
+	repos := []importedRepo{newImportedRepo([]string{"work", "api"}, "git@github.com:co/api.git")}
+
+	// A new config gets a "default" account
+	configFlag = filepath.Join(dir, "new.toml")
+	if err := writeImport(root, "", repos); err != nil {
+		t.Fatal(err)
+	}
+	c, err := config.LoadFromPath(configFlag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account := c.Accounts["default"]; account == nil || len(account.Repos["work"]) != 1 {
+		t.Errorf("new config accounts = %v, want the repo in default", c.AccountNames())
+	}
+
+	// An existing one gets its default account, whatever its name
+	configFlag = filepath.Join(dir, "config.toml")
+	data := "[accounts.home]\nroot = \"" + root + "\"\ndefault = true\n\n[accounts.work]\nroot = \"" + dir + "\"\n"
+	if err := os.WriteFile(configFlag, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeImport(root, "", repos); err != nil {
+		t.Fatal(err)
+	}
+	c, err = config.LoadFromPath(configFlag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Accounts["default"]; ok {
+		t.Errorf("accounts = %v, want no account named default", c.AccountNames())
+	}
+	if account := c.Accounts["home"]; account == nil || len(account.Repos["work"]) != 1 {
+		t.Errorf("account home = %+v, want the imported repo", account)
+	}
+}
@@ -1,11 +1,14 @@
 package commands
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 
 	"github.com/oschrenk/arbol/internal/config"
+	"github.com/oschrenk/arbol/internal/git"
 	"github.com/spf13/cobra"
 )
 
@@ -32,6 +35,16 @@ root = "~/Projects"
 # ]
 `
 
+var (
+	initInteractive bool
+	initForce       bool
+	initScan        string
+)
+
+// accountNamePattern is what init --interactive accepts as an account name:
+// a bare TOML key
+var accountNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Create a starter configuration file",
@@ -40,32 +53,181 @@ var initCmd = &cobra.Command{
 The config file will be created at:
   $XDG_CONFIG_HOME/arbol/config.toml (or ~/.config/arbol/config.toml)
 
-This command will fail if a config file already exists.`,
+or at the path given with --config.
+
+With --interactive, asks for the account name, the projects root (offering
+to create it), and whether the account is the default, and writes a minimal
+working config instead of the commented template.
+
+With --scan, the config is seeded from the git clones already in a
+directory, like 'arbol import' does: the directory becomes the account
+root, each clone's place below it its path in the tree, and its origin its
+URL. The account is named with --account, or "default".
+
+This command will fail if a config file already exists, unless --force is
+given, which replaces it after copying it to config.toml.bak.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		configPath := config.ConfigPath()
+		path := configPath()
 
 		// Check if config already exists
-		if _, err := os.Stat(configPath); err == nil {
-			return fmt.Errorf("config file already exists at %s", configPath)
+		if _, err := os.Stat(path); err == nil && !initForce {
+			return fmt.Errorf("config file already exists at %s (use --force to replace it)", path)
+		}
+
+		if initScan != "" {
+			if initInteractive {
+				return fmt.Errorf("--scan can't be combined with --interactive")
+			}
+			c, err := scanConfig(initScan)
+			if err != nil {
+				return err
+			}
+			backup, err := backupExisting(path)
+			if err != nil {
+				return err
+			}
+			if err := c.Save(path); err != nil {
+				return err
+			}
+			printCreated(path, backup)
+			fmt.Println("\nReview the file, then run 'arbol sync' to clone anything missing.")
+			return nil
+		}
+
+		if initInteractive {
+			c, err := promptConfig(stdin)
+			if err != nil {
+				return err
+			}
+			backup, err := backupExisting(path)
+			if err != nil {
+				return err
+			}
+			if err := c.Save(path); err != nil {
+				return err
+			}
+			printCreated(path, backup)
+			for name, account := range c.Accounts {
+				fmt.Printf("\nAdd repos to accounts.%s in the file, or pick up existing clones with\n", name)
+				fmt.Printf("'arbol import %s --write -a %s', then run 'arbol sync'.\n", account.Root, name)
+			}
+			return nil
 		}
 
 		// Create directory if needed
-		configDir := filepath.Dir(configPath)
+		configDir := filepath.Dir(path)
 		if err := os.MkdirAll(configDir, 0755); err != nil {
 			return fmt.Errorf("failed to create config directory: %w", err)
 		}
 
+		backup, err := backupExisting(path)
+		if err != nil {
+			return err
+		}
+
 		// Write starter config
-		if err := os.WriteFile(configPath, []byte(starterConfig), 0644); err != nil {
+		if err := os.WriteFile(path, []byte(starterConfig), 0644); err != nil {
 			return fmt.Errorf("failed to write config file: %w", err)
 		}
 
-		fmt.Printf("Created config file at %s\n", configPath)
+		printCreated(path, backup)
 		fmt.Println("\nEdit the file to add your repositories, then run 'arbol sync' to clone them.")
 		return nil
 	},
 }
 
+// backupExisting backs up the config at path before init --force replaces
+// it, returning the backup's path, or "" when there is no file to keep
+func backupExisting(path string) (string, error) {
+	if _, err := os.Stat(path); err != nil {
+		return "", nil
+	}
+	return backupConfig(path)
+}
+
+// printCreated reports the new config, and where the replaced one went
+func printCreated(path, backup string) {
+	if backup != "" {
+		fmt.Printf("Replaced config file at %s (previous one backed up to %s)\n", path, backup)
+		return
+	}
+	fmt.Printf("Created config file at %s\n", path)
+}
+
+// scanConfig returns a config with a single account rooted at dir, holding
+// the clones found below it
+func scanConfig(dir string) (*config.Config, error) {
+	root, err := filepath.Abs(config.ExpandPath(dir))
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(root); err != nil {
+		return nil, fmt.Errorf("%s does not exist", dir)
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	repos, err := scanImport(root, importDepth)
+	if err != nil {
+		return nil, err
+	}
+	account := importedAccount(root, repos)
+	if len(account.Repos) == 0 {
+		return nil, fmt.Errorf("no repos with an %s remote found in %s", git.CloneRemote, root)
+	}
+
+	name := accountFlag
+	if name == "" {
+		name = "default"
+	}
+	found := len(account.GetAllRepos(""))
+	fmt.Fprintf(os.Stderr, "Found %s in %s\n", pluralize(found, "repo", "repos"), root)
+	return &config.Config{Accounts: map[string]*config.Account{name: account}}, nil
+}
+
+// promptConfig asks for a single account and returns a config holding it.
+// Invalid answers are asked again; end of input takes the defaults.
+func promptConfig(in *bufio.Reader) (*config.Config, error) {
+	name := ask(in, "Account name", "default")
+	for !accountNamePattern.MatchString(name) {
+		fmt.Fprintln(os.Stderr, "Use letters, digits, - and _ only.")
+		name = ask(in, "Account name", "default")
+	}
+
+	var root string
+	for {
+		root = ask(in, "Projects root", "~/Projects")
+		dir := config.ExpandPath(root)
+		if !filepath.IsAbs(dir) {
+			fmt.Fprintln(os.Stderr, "The root must be an absolute path or start with ~.")
+			continue
+		}
+		info, err := os.Stat(dir)
+		if err == nil && !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "%s is not a directory.\n", dir)
+			continue
+		}
+		if os.IsNotExist(err) && confirmDefault(in, fmt.Sprintf("%s doesn't exist. Create it?", dir), true) {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+			}
+		}
+		break
+	}
+
+	account := &config.Account{
+		Default: confirmDefault(in, "Make it the default account?", true),
+		Root:    root,
+		Repos:   map[string][]config.Repo{},
+	}
+	return &config.Config{Accounts: map[string]*config.Account{name: account}}, nil
+}
+
 func init() {
+	initCmd.Flags().BoolVarP(&initInteractive, "interactive", "i", false, "Ask for the account and root instead of writing the commented template")
+	initCmd.Flags().StringVar(&initScan, "scan", "", "Seed the config from the git clones in this directory")
+	initCmd.Flags().IntVar(&importDepth, "depth", 4, "With --scan, how many directory levels to search")
+	initCmd.MarkFlagDirname("scan")
+	initCmd.Flags().BoolVar(&initForce, "force", false, "Replace an existing config, keeping a copy as config.toml.bak")
 	rootCmd.AddCommand(initCmd)
 }
@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPromptConfig(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "Projects")
+	input := strings.Join([]string{"my.account", "laptop", "relative/path", root, "y", "n"}, "\n") + "\n"
+
+	c, err := promptConfig(bufio.NewReader(strings.NewReader(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, ok := c.Accounts["laptop"]
+	if !ok || len(c.Accounts) != 1 {
+		t.Fatalf("accounts = %v, want just laptop", c.AccountNames())
+	}
+	if account.Root != root || account.Default {
+		t.Errorf("account = %+v, want root %s, not default", account, root)
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		t.Errorf("root wasn't created: %v", err)
+	}
+
+	// End of input takes every default, creating ~/Projects
+	t.Setenv("HOME", t.TempDir())
+	c, err = promptConfig(bufio.NewReader(strings.NewReader("")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account := c.Accounts["default"]; account == nil || account.Root != "~/Projects" || !account.Default {
+		t.Errorf("defaults gave %+v", c.Accounts)
+	}
+}
+
+func TestBackupExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+
+	backup, err := backupExisting(path)
+	if err != nil || backup != "" {
+		t.Fatalf("backupExisting with no file = %q, %v, want no backup", backup, err)
+	}
+	if _, err := os.Stat(path + ".bak"); !os.IsNotExist(err) {
+		t.Errorf("backup written for a missing file: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("# mine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	backup, err = backupExisting(path)
+	if err != nil || backup != path+".bak" {
+		t.Fatalf("backupExisting = %q, %v, want %s.bak", backup, err, path)
+	}
+	if data, _ := os.ReadFile(backup); string(data) != "# mine\n" {
+		t.Errorf("backup = %q, want the previous contents", data)
+	}
+}
+
+func TestScanConfig(t *testing.T) {
+	root := t.TempDir()
+	for dir, url := range map[string]string{
+		"work/backend/api": "git@github.com:co/api.git",
+		"work/backend/svc": "git@github.com:co/worker.git",
+		"work/no-origin":   "",
+	} {
+		path := filepath.Join(root, dir)
+		if out, err := exec.Command("git", "init", "-q", path).CombinedOutput(); err != nil {
+			t.Fatalf("git init: %v: %s", err, out)
+		}
+		if url != "" {
+			if out, err := exec.Command("git", "-C", path, "remote", "add", "origin", url).CombinedOutput(); err != nil {
+				t.Fatalf("git remote add: %v: %s", err, out)
+			}
+		}
+	}
+
+	c, err := scanConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := c.Accounts["default"]
+	if account == nil || !account.Default || account.Root != root {
+		t.Fatalf("accounts = %+v, want a default account rooted at %s", c.Accounts, root)
+	}
+	repos := account.Repos["work.backend"]
+	if len(repos) != 2 || len(account.Repos) != 1 {
+		t.Fatalf("repos = %+v, want api and svc under work.backend", account.Repos)
+	}
+	if repos[0].URL != "git@github.com:co/api.git" || repos[0].Name != "" {
+		t.Errorf("repos[0] = %+v, want api with its name derived from the URL", repos[0])
+	}
+	if repos[1].URL != "git@github.com:co/worker.git" || repos[1].Name != "svc" {
+		t.Errorf("repos[1] = %+v, want worker named svc", repos[1])
+	}
+
+	if _, err := scanConfig(filepath.Join(root, "work", "no-origin")); err == nil {
+		t.Error("scanConfig found repos in a directory without any")
+	}
+}
@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/oschrenk/arbol/internal/git"
+	"github.com/spf13/cobra"
+)
+
+var (
+	logSince   string
+	logAuthor  string
+	logOneline bool
+	logLimit   int
+)
+
+var logCmd = &cobra.Command{
+	Use:   "log [path...]",
+	Short: "Show recent commits across repositories",
+	Long: `Show the commits on the current branch of each cloned repository, grouped
+by repo: its path, then a line per commit with the short hash, age, author
+and subject. Repos without matching commits are left out.
+
+--since and --author filter like the git log flags of the same name: --since
+takes git's dates ("1.week", "yesterday", "2024-01-31"), --author is matched
+against the author's name and email. --limit caps the commits shown per repo
+(default 10, 0 for all), --oneline drops the age and author.
+
+Without a path argument, shows all repos in the account.
+
+Examples:
+  arbol log --since 1.week --author me@example.com   # what I did this week
+  arbol log work --oneline --limit 3                 # latest commits under work`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		account, accountName, err := getAccount()
+		if err != nil {
+			return err
+		}
+		if logLimit < 0 {
+			return fmt.Errorf("--limit must not be negative")
+		}
+
+		repos, filters, err := selectRepos(account, accountName, args)
+		if err != nil {
+			return err
+		}
+		if len(repos) == 0 {
+			if selection := describeSelection(filters); selection != "" {
+				fmt.Printf("No repos found %s in account '%s'\n", selection, accountName)
+			} else {
+				fmt.Printf("No repos configured in account '%s'\n", accountName)
+			}
+			return nil
+		}
+
+		// One more than the limit tells whether commits were left out
+		opts := git.LogOptions{Since: logSince, Author: logAuthor}
+		if logLimit > 0 {
+			opts.Limit = logLimit + 1
+		}
+
+		ctx := cmd.Context()
+		var shown, failed int
+		for _, repo := range repos {
+			if ctx.Err() != nil {
+				return errInterrupted
+			}
+			if !git.Exists(repo.FullPath) {
+				continue
+			}
+			displayPath := repo.Path + "." + repo.Name
+
+			opCtx, cancel := opContext(ctx)
+			commits, err := git.LogContext(opCtx, repo.FullPath, opts)
+			cancel()
+			if ctx.Err() != nil {
+				return errInterrupted
+			}
+			if err != nil {
+				// stderr, so a failing repo doesn't break up the stream
+				fmt.Fprintf(os.Stderr, "error: %s: %v\n", displayPath, err)
+				failed++
+				continue
+			}
+			if len(commits) == 0 {
+				continue
+			}
+
+			if shown > 0 {
+				fmt.Println()
+			}
+			shown++
+			fmt.Println(colorize(colorCyan, displayPath))
+			more := logLimit > 0 && len(commits) > logLimit
+			if more {
+				commits = commits[:logLimit]
+			}
+			for _, line := range formatCommits(commits, logOneline) {
+				fmt.Println("  " + line)
+			}
+			if more {
+				fmt.Println("  " + colorize(colorGray, glyphs.ellipsis+" more, see --limit"))
+			}
+		}
+
+		if shown == 0 && failed == 0 {
+			fmt.Println("No matching commits")
+		}
+		if failed > 0 {
+			return fmt.Errorf("%s could not be read", pluralize(failed, "repo's log", "repos' logs"))
+		}
+		return nil
+	},
+	ValidArgsFunction: completeRepoPath,
+}
+
+// formatCommits formats a line per commit: hash and subject, and unless
+// oneline the age and author between them, aligned across the commits
+func formatCommits(commits []git.CommitInfo, oneline bool) []string {
+	authorWidth := 0
+	for _, commit := range commits {
+		authorWidth = max(authorWidth, visibleWidth(commit.Author))
+	}
+	lines := make([]string, len(commits))
+	for i, commit := range commits {
+		hash := colorize(colorYellow, commit.Hash)
+		subject := stripAnsi(commit.Subject)
+		if oneline {
+			lines[i] = hash + " " + subject
+			continue
+		}
+		age := colorize(colorGray, padRight(formatRelativeTime(commit.Time), 4))
+		lines[i] = strings.Join([]string{hash, age, padRight(stripAnsi(commit.Author), authorWidth), subject}, "  ")
+	}
+	return lines
+}
+
+func init() {
+	logCmd.Flags().StringVar(&logSince, "since", "", "Only commits after this date, as git log --since takes it (e.g. 1.week)")
+	logCmd.Flags().StringVar(&logAuthor, "author", "", "Only commits whose author name or email matches this pattern")
+	logCmd.Flags().BoolVar(&logOneline, "oneline", false, "Show only the hash and subject of each commit")
+	logCmd.Flags().IntVar(&logLimit, "limit", 10, "Show at most N commits per repo, 0 for all")
+	logCmd.Flags().StringArrayVar(&excludeFlags, "exclude", nil, "Skip repos matching this path or glob (repeatable)")
+	logCmd.Flags().StringArrayVar(&tagFlags, "tag", nil, "Only repos with this tag (repeatable, all must match)")
+	logCmd.Flags().BoolVar(&tagAnyFlag, "tag-any", false, "With several --tag, select repos with any of them")
+	rootCmd.AddCommand(logCmd)
+}
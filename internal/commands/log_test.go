@@ -0,0 +1,31 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/oschrenk/arbol/internal/git"
+)
+
+func TestFormatCommits(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	now := time.Now()
+	commits := []git.CommitInfo{
+		{Hash: "a1b2c3d", Time: now.Add(-2 * time.Hour), Author: "Ann", Subject: "Fix login redirect"},
+		{Hash: "e4f5a6b", Time: now.Add(-3 * 24 * time.Hour), Author: "Bob Jones", Subject: "Add \x1b[31mred\x1b[0m"},
+	}
+
+	want := []string{
+		"a1b2c3d  2h    Ann        Fix login redirect",
+		"e4f5a6b  3d    Bob Jones  Add red",
+	}
+	if got := formatCommits(commits, false); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	want = []string{"a1b2c3d Fix login redirect", "e4f5a6b Add red"}
+	if got := formatCommits(commits, true); !slices.Equal(got, want) {
+		t.Errorf("oneline: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oschrenk/arbol/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var mvCmd = &cobra.Command{
+	Use:   "mv <path> <new-path>",
+	Short: "Move a repository to another place in the tree",
+	Long: `Move a repository to another dotted path, updating the config and, if
+the repo is cloned, renaming its directory to match.
+
+The source must select exactly one repo. The destination is the repo's full
+new path, container and name: the last segment becomes the directory name,
+so a move can also rename. Parent directories are created as needed.
+
+Nothing is overwritten: a destination that's already in the config or
+already exists on disk is an error. If the repo isn't cloned, only the
+config changes. The config is validated before anything is written, and the
+previous file is kept as config.toml.bak, since rewriting it drops comments
+and formatting.
+
+Examples:
+  arbol mv personal.golang.app work.golang.app   # move to another subtree
+  arbol mv work.backend.api work.backend.gateway # rename in place`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		account, accountName, err := getAccount()
+		if err != nil {
+			return err
+		}
+
+		src, err := resolveConfigRepo(account, accountName, args[0])
+		if err != nil {
+			return err
+		}
+		srcID := src.Path + "." + src.Name
+
+		dstID := args[1]
+		i := strings.LastIndex(dstID, ".")
+		if i == -1 {
+			return fmt.Errorf("destination '%s' must be a full repo path like %s", dstID, srcID)
+		}
+		dstPath, dstName := dstID[:i], dstID[i+1:]
+		if dstID == srcID {
+			return fmt.Errorf("%s is already at %s", srcID, dstID)
+		}
+
+		repo, err := account.RemoveRepo(src.Path, src.Name)
+		if err != nil {
+			return err
+		}
+		repo.Name = ""
+		if dstName != config.RepoName(repo.URL) {
+			repo.Name = dstName
+		}
+		if err := account.AddRepo(dstPath, repo); err != nil {
+			return fmt.Errorf("cannot move %s to %s: %w", srcID, dstID, err)
+		}
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("cannot move %s to %s: %w", srcID, dstID, err)
+		}
+
+		dstDir := filepath.Join(config.ExpandPath(account.Root), strings.ReplaceAll(dstPath, ".", string(filepath.Separator)), dstName)
+		moved := false
+		if _, err := os.Stat(src.FullPath); err == nil {
+			if _, err := os.Lstat(dstDir); err == nil {
+				return fmt.Errorf("cannot move %s to %s: %s already exists", srcID, dstID, dstDir)
+			}
+			if err := os.MkdirAll(filepath.Dir(dstDir), 0755); err != nil {
+				return fmt.Errorf("failed to create directory: %w", err)
+			}
+			if err := os.Rename(src.FullPath, dstDir); err != nil {
+				return fmt.Errorf("failed to move directory: %w", err)
+			}
+			moved = true
+		}
+
+		if err := saveConfig(cfg, configPath(), true); err != nil {
+			if moved {
+				// Keep disk and config in agreement
+				if undoErr := os.Rename(dstDir, src.FullPath); undoErr != nil {
+					return fmt.Errorf("%w (and %s could not be moved back: %v)", err, dstDir, undoErr)
+				}
+			}
+			return err
+		}
+
+		fmt.Printf("Moved %s to %s\n", srcID, dstID)
+		if moved {
+			fmt.Printf("  %s -> %s\n", src.FullPath, dstDir)
+		} else {
+			fmt.Println("  not cloned, only the config changed")
+		}
+		return nil
+	},
+	ValidArgsFunction: completeRepoPath,
+}
+
+func init() {
+	rootCmd.AddCommand(mvCmd)
+}
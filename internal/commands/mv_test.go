@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oschrenk/arbol/internal/config"
+)
+
+// setupConfig writes a config with account home rooted at a temp dir
+// holding the given repos entries, loads it as the current config, and
+// returns the root and the config path
+func setupConfig(t *testing.T, repos string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	root := filepath.Join(dir, "src")
+	path := filepath.Join(dir, "config.toml")
+	data := "[accounts.home]\nroot = \"" + root + "\"\ndefault = true\n\n[accounts.home.repos]\n" + repos
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	prevCfg, prevConfig, prevAccount := cfg, configFlag, accountFlag
+	t.Cleanup(func() { cfg, configFlag, accountFlag = prevCfg, prevConfig, prevAccount })
+	t.Setenv(accountEnv, "")
+	c, err := config.LoadFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, configFlag, accountFlag = c, path, ""
+	return root, path
+}
+
+// cloneDir creates a stand-in clone at dir, a directory with a .git in it
+func cloneDir(t *testing.T, dir string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMvRefusesExistingDestination(t *testing.T) {
+	setupConfig(t, `work = [{ url = "git@github.com:co/api.git" }, { url = "git@github.com:co/web.git" }]
+`)
+
+	// In the config
+	err := mvCmd.RunE(mvCmd, []string{"work.api", "work.web"})
+	if err == nil || !strings.Contains(err.Error(), "cannot move work.api to work.web") {
+		t.Errorf("mv onto a configured repo = %v, want an error", err)
+	}
+
+	// On disk
+	root, path := setupConfig(t, `work = [{ url = "git@github.com:co/api.git" }]
+`)
+	cloneDir(t, filepath.Join(root, "work", "api"))
+	if err := os.MkdirAll(filepath.Join(root, "other", "api"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	err = mvCmd.RunE(mvCmd, []string{"work.api", "other.api"})
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("mv onto an existing directory = %v, want an error", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "work", "api", ".git")); err != nil {
+		t.Errorf("source clone was touched: %v", err)
+	}
+	if _, err := os.Stat(path + ".bak"); !os.IsNotExist(err) {
+		t.Errorf("config was rewritten: %v", err)
+	}
+}
+
+func TestMvNotCloned(t *testing.T) {
+	root, path := setupConfig(t, `work = [{ url = "git@github.com:co/api.git" }]
+`)
+	if err := mvCmd.RunE(mvCmd, []string{"work.api", "personal.api"}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := config.LoadFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := c.Accounts["home"]
+	if len(account.Repos["work"]) != 0 || len(account.Repos["personal"]) != 1 {
+		t.Errorf("repos = %+v, want api moved to personal", account.Repos)
+	}
+	if _, err := os.Stat(filepath.Join(root, "personal")); !os.IsNotExist(err) {
+		t.Errorf("directories created for an uncloned repo: %v", err)
+	}
+}
+
+func TestMvName(t *testing.T) {
+	_, path := setupConfig(t, `work = [{ url = "git@github.com:co/api.git", name = "gateway" }]
+`)
+
+	// Renaming to another name keeps it
+	if err := mvCmd.RunE(mvCmd, []string{"work.gateway", "work.edge"}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := config.LoadFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repos := c.Accounts["home"].Repos["work"]; len(repos) != 1 || repos[0].Name != "edge" {
+		t.Errorf("repos = %+v, want api named edge", repos)
+	}
+
+	// Renaming to the name from the URL clears it
+	cfg = c
+	if err := mvCmd.RunE(mvCmd, []string{"work.edge", "work.api"}); err != nil {
+		t.Fatal(err)
+	}
+	c, err = config.LoadFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repos := c.Accounts["home"].Repos["work"]; len(repos) != 1 || repos[0].Name != "" {
+		t.Errorf("repos = %+v, want api without a name", repos)
+	}
+}
+
+func TestMvSaveFailureMovesBack(t *testing.T) {
+	root, path := setupConfig(t, `work = [{ url = "git@github.com:co/api.git" }]
+`)
+	src := filepath.Join(root, "work", "api")
+	cloneDir(t, src)
+
+	// A config that can't be backed up fails the save after the move
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := mvCmd.RunE(mvCmd, []string{"work.api", "personal.api"}); err == nil {
+		t.Fatal("mv succeeded without a config to save")
+	}
+	if _, err := os.Stat(filepath.Join(src, ".git")); err != nil {
+		t.Errorf("clone wasn't moved back: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "personal", "api")); !os.IsNotExist(err) {
+		t.Errorf("clone left at the destination: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+var notifyFlag bool
+
+// notifyMessage summarizes the repos that need a look for --notify, e.g.
+// "3 repos behind, 1 dirty", or "" when none do
+func notifyMessage(states []repoState) string {
+	var behind, dirty int
+	for _, state := range states {
+		if !state.Cloned || state.Err != nil {
+			continue
+		}
+		if !state.Status.IsDetached && state.Status.Behind > 0 {
+			behind++
+		}
+		if state.Status.IsDirty {
+			dirty++
+		}
+	}
+
+	var parts []string
+	if behind > 0 {
+		parts = append(parts, pluralize(behind, "repo", "repos")+" behind")
+	}
+	if dirty > 0 {
+		parts = append(parts, fmt.Sprintf("%d dirty", dirty))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// notify shows a desktop notification. A notify_command setting runs through
+// the shell with ARBOL_NOTIFY_TITLE and ARBOL_NOTIFY_MESSAGE set; otherwise
+// the platform's notifier is used: terminal-notifier or osascript on macOS,
+// notify-send on Linux, a PowerShell toast on Windows.
+func notify(ctx context.Context, title, message string) error {
+	if command := cfg.Settings.NotifyCommand; command != "" {
+		env := []string{"ARBOL_NOTIFY_TITLE=" + title, "ARBOL_NOTIFY_MESSAGE=" + message}
+		if err := runHooks(ctx, "", []string{command}, env, os.Stderr); err != nil {
+			return fmt.Errorf("notify_command %w", err)
+		}
+		return nil
+	}
+
+	cmd, err := notifierCommand(ctx, title, message)
+	if err != nil {
+		return err
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to send notification: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// notifierCommand returns the platform's notification command
+func notifierCommand(ctx context.Context, title, message string) (*exec.Cmd, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		if _, err := exec.LookPath("terminal-notifier"); err == nil {
+			return exec.CommandContext(ctx, "terminal-notifier", "-title", title, "-message", message), nil
+		}
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(message), appleScriptString(title))
+		return exec.CommandContext(ctx, "osascript", "-e", script), nil
+	case "windows":
+		// The strings travel in the environment, so they need no quoting
+		script := `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $xml.GetElementsByTagName('text')
+$text.Item(0).AppendChild($xml.CreateTextNode($env:ARBOL_NOTIFY_TITLE)) > $null
+$text.Item(1).AppendChild($xml.CreateTextNode($env:ARBOL_NOTIFY_MESSAGE)) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('arbol').Show([Windows.UI.Notifications.ToastNotification]::new($xml))`
+		cmd := exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command", script)
+		cmd.Env = append(os.Environ(), "ARBOL_NOTIFY_TITLE="+title, "ARBOL_NOTIFY_MESSAGE="+message)
+		return cmd, nil
+	}
+	if _, err := exec.LookPath("notify-send"); err != nil {
+		return nil, fmt.Errorf("no notifier found: install notify-send or set notify_command in [settings]")
+	}
+	return exec.CommandContext(ctx, "notify-send", title, message), nil
+}
+
+// appleScriptString quotes s as an AppleScript string literal
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
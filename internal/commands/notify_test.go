@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/oschrenk/arbol/internal/git"
+)
+
+func TestNotifyMessage(t *testing.T) {
+	clean := repoState{Cloned: true, Status: &git.RepoStatus{Branch: "main"}}
+	behind := repoState{Cloned: true, Status: &git.RepoStatus{Branch: "main", Behind: 3}}
+	dirtyBehind := repoState{Cloned: true, Status: &git.RepoStatus{Branch: "main", Behind: 1, IsDirty: true}}
+	detached := repoState{Cloned: true, Status: &git.RepoStatus{IsDetached: true, Behind: 2}}
+
+	cases := []struct {
+		name   string
+		states []repoState
+		want   string
+	}{
+		{"all clean", []repoState{clean, {}, detached}, ""},
+		{"one behind", []repoState{clean, behind}, "1 repo behind"},
+		{"behind and dirty", []repoState{behind, dirtyBehind}, "2 repos behind, 1 dirty"},
+	}
+	for _, c := range cases {
+		if got := notifyMessage(c.states); got != c.want {
+			t.Errorf("%s: notifyMessage() = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestAppleScriptString(t *testing.T) {
+	if got, want := appleScriptString(`say "hi" \ bye`), `"say \"hi\" \\ bye"`; got != want {
+		t.Errorf("appleScriptString() = %s, want %s", got, want)
+	}
+}
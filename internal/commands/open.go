@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+
+	"github.com/oschrenk/arbol/internal/git"
+	"github.com/spf13/cobra"
+)
+
+var printFlag bool
+
+var openCmd = &cobra.Command{
+	Use:   "open <path>",
+	Short: "Open a repository's web page",
+	Long: `Open the web page of a repository in the browser.
+
+The path must select exactly one repo. Its URL is converted to an https URL,
+so git@github.com:user/repo.git opens https://github.com/user/repo. Other
+hosts are mapped the same way (git@host:path -> https://host/path).
+
+Use --print to print the URL instead of opening it.
+
+Examples:
+  arbol open personal.dotfiles          # open in the browser
+  arbol open work.backend.api --print   # print the URL`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		account, accountName, err := getAccount()
+		if err != nil {
+			return err
+		}
+
+		repo, err := resolveRepo(account, accountName, args[0])
+		if err != nil {
+			return err
+		}
+
+		url, err := git.WebURL(repo.Repo.URL)
+		if err != nil {
+			return err
+		}
+
+		if printFlag {
+			fmt.Println(url)
+			return nil
+		}
+		return openBrowser(url)
+	},
+	ValidArgsFunction: completeRepoPath,
+}
+
+func init() {
+	openCmd.Flags().BoolVar(&printFlag, "print", false, "Print the URL instead of opening it")
+	rootCmd.AddCommand(openCmd)
+}
+
+// openBrowser opens url with the platform's default handler
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		// The empty argument is the window title; start treats the first
+		// quoted argument as one
+		cmd = exec.Command("cmd", "/c", "start", "", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to open %s: %w", url, err)
+	}
+	return cmd.Process.Release()
+}
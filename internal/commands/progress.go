@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// progressFlag asks sync for a single updating progress line instead of a
+// line per repo; it defaults to on when stdout is a terminal
+var progressFlag bool
+
+// bar is the progress line of the running sync, nil when sync prints a line
+// per repo. printStep leaves the per-repo lines out while it's set, and
+// printProblem draws around it.
+var bar *progressBar
+
+// progressBar redraws one line, e.g. "[12/48] clone work.backend.api",
+// in place with a carriage return
+type progressBar struct {
+	w      io.Writer
+	width  int // terminal columns, 0 if unknown
+	total  int
+	done   int
+	failed int
+	line   string // what's drawn, to redraw after a problem is printed
+}
+
+// newProgressBar returns a progress line for total repos on stdout
+func newProgressBar(total int) *progressBar {
+	return &progressBar{w: os.Stdout, width: terminalWidth(), total: total}
+}
+
+// show draws the line for action on repo, done repos in
+func (p *progressBar) show(action, repo string) {
+	line := fmt.Sprintf("[%d/%d] %s %s", p.done, p.total, action, repo)
+	if p.failed > 0 {
+		line = fmt.Sprintf("[%d/%d, %d failed] %s %s", p.done, p.total, p.failed, action, repo)
+	}
+	// A wrapped line can't be redrawn with \r
+	if p.width > 1 {
+		line = truncate(line, p.width-1)
+	}
+	p.line = line
+	p.redraw()
+}
+
+// redraw draws the current line again
+func (p *progressBar) redraw() {
+	fmt.Fprintf(p.w, "\r\033[K%s", p.line)
+}
+
+// clear erases the line, leaving the cursor at its start
+func (p *progressBar) clear() {
+	fmt.Fprint(p.w, "\r\033[K")
+}
+
+// useProgress reports whether sync should draw a progress line: as
+// --progress says when given, otherwise when stdout is a terminal. Never
+// with --quiet or --dry-run, which print for a reader of the whole list.
+func useProgress(changed bool) bool {
+	if quietFlag > 0 || dryRunFlag {
+		return false
+	}
+	if changed {
+		return progressFlag
+	}
+	return isTerminal()
+}
+
+// printProblem prints an error or abort line, which --quiet and the
+// progress line don't hide
+func printProblem(format string, args ...any) {
+	if bar != nil {
+		bar.clear()
+	}
+	fmt.Printf(format, args...)
+	if bar != nil {
+		bar.redraw()
+	}
+}
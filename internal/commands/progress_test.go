@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestProgressBar(t *testing.T) {
+	var out bytes.Buffer
+	p := &progressBar{w: &out, width: 30, total: 48}
+
+	p.done = 12
+	p.show("clone", "work.api")
+	if got, want := out.String(), "\r\033[K[12/48] clone work.api"; got != want {
+		t.Errorf("show() wrote %q, want %q", got, want)
+	}
+
+	out.Reset()
+	p.failed = 1
+	p.show("fetch", "work.backend.services.billing")
+	line := p.line
+	if len([]rune(line)) != 29 || line[:17] != "[12/48, 1 failed]" {
+		t.Errorf("show() drew %q, want the failed count cut to 29 columns", line)
+	}
+
+	out.Reset()
+	p.clear()
+	p.redraw()
+	if got, want := out.String(), "\r\033[K\r\033[K"+line; got != want {
+		t.Errorf("clear(), redraw() wrote %q, want %q", got, want)
+	}
+}
+
+func TestUseProgress(t *testing.T) {
+	defer func(quiet int, dryRun, progress bool) {
+		quietFlag, dryRunFlag, progressFlag = quiet, dryRun, progress
+	}(quietFlag, dryRunFlag, progressFlag)
+
+	cases := []struct {
+		name     string
+		quiet    int
+		dryRun   bool
+		progress bool
+		changed  bool
+		want     bool
+	}{
+		{"forced on", 0, false, true, true, true},
+		{"forced off", 0, false, false, true, false},
+		{"not a terminal", 0, false, false, false, false},
+		{"quiet", 1, false, true, true, false},
+		{"dry run", 0, true, true, true, false},
+	}
+	for _, c := range cases {
+		quietFlag, dryRunFlag, progressFlag = c.quiet, c.dryRun, c.progress
+		if got := useProgress(c.changed); got != c.want {
+			t.Errorf("%s: useProgress(%v) = %v, want %v", c.name, c.changed, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/oschrenk/arbol/internal/git"
+	"github.com/spf13/cobra"
+)
+
+var pullAutostash bool
+
+var pullCmd = &cobra.Command{
+	Use:   "pull [path...]",
+	Short: "Update repositories from their remote",
+	Long: `Pull the current branch of each cloned repository from its remote (the
+repo's remote, default origin).
+
+How upstream commits are integrated is set by pull_strategy, per repo or as
+a default in [settings]:
+  ff-only  only fast-forward; a branch with local commits fails (default)
+  rebase   replay local commits on top of upstream
+  merge    create a merge commit where a fast-forward isn't possible
+A rebase or merge that stops on conflicts is aborted, leaving the repo as it
+was. With --verbose, each repo's line names the strategy it was pulled with.
+
+Repos that aren't cloned, are bare, have a detached HEAD, or whose branch has
+no tracking branch on the remote (as of the last fetch) are skipped. So are
+repos with uncommitted changes that would be rebased, as git refuses to
+rebase a dirty tree, unless --autostash is given: it stashes uncommitted
+changes before pulling and reapplies them afterwards, with any strategy.
+The summary lists the autostashed repos to review, and those whose changes
+conflicted with upstream on reapplying and were kept in the stash. Repos
+that already had stashed changes say so, as a reminder they're there.
+
+Without a path argument, pulls all repos in the account.
+
+Examples:
+  arbol pull                    # pull everything
+  arbol pull work.backend       # repos under work.backend
+  arbol pull --autostash        # also repos with uncommitted changes
+  arbol pull --exclude 'work.legacy.*'`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		account, accountName, err := getAccount()
+		if err != nil {
+			return err
+		}
+
+		repos, filters, err := selectRepos(account, accountName, args)
+		if err != nil {
+			return err
+		}
+		if len(repos) == 0 {
+			if selection := describeSelection(filters); selection != "" {
+				fmt.Printf("No repos found %s in account '%s'\n", selection, accountName)
+			} else {
+				fmt.Printf("No repos configured in account '%s'\n", accountName)
+			}
+			return nil
+		}
+
+		ctx := cmd.Context()
+		var pulled, upToDate, skipped, failed int
+		var autostashed, stashKept []string
+
+		for _, repo := range repos {
+			if ctx.Err() != nil {
+				break
+			}
+			displayPath := repo.Path + "." + repo.Name
+			remote := repo.Repo.RemoteName()
+			strategy := cfg.Settings.PullStrategyFor(repo.Repo)
+
+			if !git.Exists(repo.FullPath) {
+				fmt.Printf("  skip   %s (not cloned)\n", displayPath)
+				skipped++
+				continue
+			}
+
+			opCtx, cancel := opContext(ctx)
+			status, err := git.StatusContext(opCtx, repo.FullPath, remote)
+			cancel()
+			if ctx.Err() != nil {
+				break
+			}
+			if err != nil {
+				fmt.Printf("  error  %s: %v\n", displayPath, err)
+				failed++
+				continue
+			}
+			if reason := pullSkipReason(status, remote, strategy, pullAutostash); reason != "" {
+				fmt.Printf("  skip   %s (%s)\n", displayPath, reason)
+				skipped++
+				continue
+			}
+
+			opCtx, cancel = opContext(ctx)
+			// Counted before the pull, so changes kept from an autostash
+			// aren't reported twice
+			stashes, _ := git.StashCountContext(opCtx, repo.FullPath)
+			result, err := git.PullContext(opCtx, repo.FullPath, remote, status.Branch, strategy, pullAutostash)
+			cancel()
+			var notes []string
+			if note := stashNote(stashes); note != "" {
+				notes = append(notes, note)
+			}
+			if result.StashKept {
+				notes = append(notes, "changes left in the stash")
+				stashKept = append(stashKept, displayPath)
+			} else if result.Autostashed {
+				notes = append(notes, "autostashed")
+			}
+			if result.Autostashed {
+				autostashed = append(autostashed, displayPath)
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			switch {
+			case err != nil:
+				fmt.Printf("  error  %s: %v%s\n", displayPath, err, pullDetail(strategy, notes...))
+				failed++
+			case result.Updated:
+				fmt.Printf("  pull   %s%s\n", displayPath, pullDetail(strategy, notes...))
+				pulled++
+			default:
+				fmt.Printf("  ok     %s%s\n", displayPath, pullDetail(strategy, append([]string{"up to date"}, notes...)...))
+				upToDate++
+			}
+		}
+
+		var summary []string
+		if pulled > 0 {
+			summary = append(summary, fmt.Sprintf("%d pulled", pulled))
+		}
+		if upToDate > 0 {
+			summary = append(summary, fmt.Sprintf("%d up to date", upToDate))
+		}
+		if skipped > 0 {
+			summary = append(summary, fmt.Sprintf("%d skipped", skipped))
+		}
+		if failed > 0 {
+			summary = append(summary, fmt.Sprintf("%d failed", failed))
+		}
+		if len(summary) == 0 {
+			summary = append(summary, "nothing to do")
+		}
+		if ctx.Err() != nil {
+			fmt.Printf("\nInterrupted, %d pulled so far\n", pulled)
+			fmt.Printf("Summary: %s\n", strings.Join(summary, ", "))
+			printStashed(autostashed, stashKept)
+			return errInterrupted
+		}
+		fmt.Printf("\nSummary: %s\n", strings.Join(summary, ", "))
+		printStashed(autostashed, stashKept)
+		if failed > 0 {
+			return fmt.Errorf("%s failed to pull", pluralize(failed, "repo", "repos"))
+		}
+		return nil
+	},
+	ValidArgsFunction: completeRepoPath,
+}
+
+// pullSkipReason returns why a repo with status can't be pulled from remote
+// with strategy, or "" if it can
+func pullSkipReason(status *git.RepoStatus, remote, strategy string, autostash bool) string {
+	switch {
+	case status.IsBare:
+		return "bare repository"
+	case status.IsDetached:
+		return "detached HEAD"
+	case status.NoTracking:
+		return fmt.Sprintf("no tracking branch on %s", remote)
+	case status.IsDirty && strategy == "rebase" && !autostash:
+		return fmt.Sprintf("%s, can't rebase, use --autostash", pluralize(status.DirtyFiles, "dirty file", "dirty files"))
+	}
+	return ""
+}
+
+// pullDetail formats the parenthesized notes after a repo's pull line, and
+// with --verbose the strategy it was pulled with
+func pullDetail(strategy string, notes ...string) string {
+	if verboseFlag {
+		notes = append(notes, strategy)
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(notes, ", ") + ")"
+}
+
+// stashNote is the note for a repo whose stash holds stashes entries, ""
+// for none
+func stashNote(stashes int) string {
+	if stashes == 0 {
+		return ""
+	}
+	return "has " + pluralize(stashes, "stash entry", "stash entries")
+}
+
+// printStashed lists the repos pull autostashed, which are worth a look,
+// and those whose changes are still in the stash after conflicting
+func printStashed(autostashed, stashKept []string) {
+	if len(autostashed) > 0 {
+		fmt.Printf("Autostashed, review: %s\n", strings.Join(autostashed, ", "))
+	}
+	if len(stashKept) > 0 {
+		fmt.Printf("Conflicted on reapplying, changes kept in the stash: %s\n", strings.Join(stashKept, ", "))
+	}
+}
+
+func init() {
+	pullCmd.Flags().BoolVar(&pullAutostash, "autostash", false, "Stash uncommitted changes before pulling and reapply them afterwards")
+	pullCmd.Flags().StringArrayVar(&excludeFlags, "exclude", nil, "Skip repos matching this path or glob (repeatable)")
+	pullCmd.Flags().StringArrayVar(&tagFlags, "tag", nil, "Only repos with this tag (repeatable, all must match)")
+	pullCmd.Flags().BoolVar(&tagAnyFlag, "tag-any", false, "With several --tag, select repos with any of them")
+	pullCmd.Flags().BoolVar(&includeDisabledFlag, "include-disabled", false, "Also select repos with enabled = false")
+	rootCmd.AddCommand(pullCmd)
+}
@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/oschrenk/arbol/internal/git"
+)
+
+func TestPullSkipReason(t *testing.T) {
+	cases := []struct {
+		name      string
+		status    git.RepoStatus
+		strategy  string
+		autostash bool
+		want      string
+	}{
+		{"clean", git.RepoStatus{Branch: "main"}, "ff-only", false, ""},
+		{"bare", git.RepoStatus{IsBare: true}, "ff-only", false, "bare repository"},
+		{"detached", git.RepoStatus{IsDetached: true}, "ff-only", false, "detached HEAD"},
+		{"no tracking", git.RepoStatus{Branch: "wip", NoTracking: true}, "ff-only", false, "no tracking branch on origin"},
+		{"dirty ff-only", git.RepoStatus{Branch: "main", IsDirty: true, DirtyFiles: 2}, "ff-only", false, ""},
+		{"dirty rebase", git.RepoStatus{Branch: "main", IsDirty: true, DirtyFiles: 1}, "rebase", false, "1 dirty file, can't rebase, use --autostash"},
+		{"dirty rebase autostash", git.RepoStatus{Branch: "main", IsDirty: true, DirtyFiles: 1}, "rebase", true, ""},
+	}
+	for _, c := range cases {
+		if got := pullSkipReason(&c.status, "origin", c.strategy, c.autostash); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestStashNote(t *testing.T) {
+	for stashes, want := range map[int]string{0: "", 1: "has 1 stash entry", 3: "has 3 stash entries"} {
+		if got := stashNote(stashes); got != want {
+			t.Errorf("stashNote(%d) = %q, want %q", stashes, got, want)
+		}
+	}
+}
+
+func TestPullDetail(t *testing.T) {
+	defer func() { verboseFlag = false }()
+	if got := pullDetail("rebase"); got != "" {
+		t.Errorf("no notes: got %q, want nothing", got)
+	}
+	if got := pullDetail("rebase", "up to date", "autostashed"); got != " (up to date, autostashed)" {
+		t.Errorf("notes: got %q", got)
+	}
+	verboseFlag = true
+	if got := pullDetail("rebase"); got != " (rebase)" {
+		t.Errorf("verbose: got %q", got)
+	}
+	if got := pullDetail("merge", "up to date"); got != " (up to date, merge)" {
+		t.Errorf("verbose with note: got %q", got)
+	}
+}
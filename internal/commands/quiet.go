@@ -0,0 +1,27 @@
+package commands
+
+import "fmt"
+
+// quietFlag counts -q on sync and fetch: once hides the plan, the per-repo
+// lines, and git's own output; twice also hides the summary when nothing
+// failed. Errors are always printed.
+var quietFlag int
+
+// printStep prints a line of progress, unless --quiet or the progress line
+// stands in for it
+func printStep(format string, args ...any) {
+	if quietFlag == 0 && bar == nil {
+		fmt.Printf(format, args...)
+	}
+}
+
+// printSummary prints the closing summary line: after a blank line
+// normally, on its own with -q, and with -qq only when something failed
+func printSummary(summary string, failed bool) {
+	switch {
+	case quietFlag == 0:
+		fmt.Printf("\nSummary: %s\n", summary)
+	case quietFlag == 1 || failed:
+		fmt.Printf("Summary: %s\n", summary)
+	}
+}
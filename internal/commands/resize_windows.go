@@ -0,0 +1,11 @@
+//go:build windows
+
+package commands
+
+import "os"
+
+// notifyResize returns a nil channel, as Windows has no SIGWINCH; a watch
+// fits a resized console on its next redraw
+func notifyResize() (<-chan os.Signal, func()) {
+	return nil, func() {}
+}
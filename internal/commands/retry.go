@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"github.com/oschrenk/arbol/internal/git"
+)
+
+// retriesFlag is how many times a clone or fetch that failed with a
+// transient error is retried
+var retriesFlag int
+
+// retryBackoff is the wait before the first retry; it doubles for each
+// further one
+var retryBackoff = time.Second
+
+// withRetries runs op, each attempt with its own --timeout, retrying up to
+// --retries times with exponential backoff while it fails with an error
+// git.IsTransient accepts. Retries are announced for displayPath. It returns
+// how many retries were made along with op's last error.
+func withRetries(ctx context.Context, displayPath string, op func(ctx context.Context) error) (int, error) {
+	return withRetriesTo(ctx, displayPath, printStep, op)
+}
+
+// withRetriesTo is like withRetries but announces retries with step, e.g.
+// into a repo's collected output
+func withRetriesTo(ctx context.Context, displayPath string, step func(format string, args ...any), op func(ctx context.Context) error) (int, error) {
+	wait := retryBackoff
+	for retries := 0; ; retries++ {
+		opCtx, cancel := opContext(ctx)
+		err := op(opCtx)
+		cancel()
+		if err == nil || ctx.Err() != nil || retries >= retriesFlag || !git.IsTransient(err) {
+			return retries, err
+		}
+
+		step("  retry %s (%d/%d in %s): %v\n", displayPath, retries+1, retriesFlag, wait, err)
+		select {
+		case <-ctx.Done():
+			return retries, ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// retriesSuffix notes on an error line that it's the last of several attempts
+func retriesSuffix(retries int) string {
+	if retries == 0 {
+		return ""
+	}
+	return " (after " + pluralize(retries, "retry", "retries") + ")"
+}
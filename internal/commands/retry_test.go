@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithRetries(t *testing.T) {
+	defer func(backoff time.Duration, retries int) {
+		retryBackoff, retriesFlag = backoff, retries
+	}(retryBackoff, retriesFlag)
+	retryBackoff = time.Millisecond
+	retriesFlag = 2
+
+	transient := errors.New("dial tcp: connection refused")
+	cases := []struct {
+		name        string
+		errs        []error // returned by successive attempts, then nil
+		wantRetries int
+		wantErr     bool
+	}{
+		{"succeeds", nil, 0, false},
+		{"recovers", []error{transient}, 1, false},
+		{"gives up", []error{transient, transient, transient, transient}, 2, true},
+		{"permanent", []error{errors.New("authentication required")}, 0, true},
+	}
+	for _, c := range cases {
+		attempts := 0
+		retries, err := withRetries(context.Background(), "x.repo", func(ctx context.Context) error {
+			attempts++
+			if attempts <= len(c.errs) {
+				return c.errs[attempts-1]
+			}
+			return nil
+		})
+		if retries != c.wantRetries || (err != nil) != c.wantErr {
+			t.Errorf("%s: withRetries() = %d, %v, want %d retries, error %v", c.name, retries, err, c.wantRetries, c.wantErr)
+		}
+		if attempts != retries+1 {
+			t.Errorf("%s: %d attempts for %d retries", c.name, attempts, retries)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/oschrenk/arbol/internal/git"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rmDelete bool
+	rmForce  bool
+)
+
+var rmCmd = &cobra.Command{
+	Use:   "rm <path>",
+	Short: "Remove a repository from the config",
+	Long: `Remove a repository from the config. The path must select exactly one
+repo; if it matches several, they are listed and nothing is removed.
+
+The clone stays on disk unless --delete is given, which deletes its directory
+after asking for confirmation (skipped with --yes). Repos with uncommitted
+changes or unpushed commits are never deleted unless --force is given.
+
+The previous config file is kept as config.toml.bak, since rewriting it
+drops comments and formatting.
+
+Examples:
+  arbol rm personal.old-blog            # forget the repo, keep the clone
+  arbol rm personal.old-blog --delete   # and delete the clone`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		account, accountName, err := getAccount()
+		if err != nil {
+			return err
+		}
+
+		repo, err := resolveConfigRepo(account, accountName, args[0])
+		if err != nil {
+			return err
+		}
+		displayPath := repo.Path + "." + repo.Name
+
+		deleteDir := false
+		if rmDelete {
+			if _, err := os.Stat(repo.FullPath); err == nil {
+				if !rmForce {
+					if err := checkDeletable(cmd, repo.FullPath, repo.Repo.RemoteName()); err != nil {
+						return fmt.Errorf("not deleting %s: %w (use --force to delete anyway)", displayPath, err)
+					}
+				}
+				if !yesFlag && !confirm(stdin, fmt.Sprintf("Delete %s?", repo.FullPath)) {
+					return fmt.Errorf("aborted, nothing was changed")
+				}
+				deleteDir = true
+			}
+		}
+
+		if _, err := account.RemoveRepo(repo.Path, repo.Name); err != nil {
+			return err
+		}
+		if err := saveConfig(cfg, configPath(), true); err != nil {
+			return err
+		}
+		fmt.Printf("Removed %s from account '%s'\n", displayPath, accountName)
+
+		switch {
+		case deleteDir:
+			if err := os.RemoveAll(repo.FullPath); err != nil {
+				return fmt.Errorf("failed to delete %s: %w", repo.FullPath, err)
+			}
+			fmt.Printf("  deleted %s\n", repo.FullPath)
+		case rmDelete:
+			fmt.Println("  not cloned, nothing to delete")
+		}
+		return nil
+	},
+	ValidArgsFunction: completeRepoPath,
+}
+
+// checkDeletable returns why deleting the clone at path would lose work:
+// uncommitted changes, unpushed commits, or a status that can't be read
+func checkDeletable(cmd *cobra.Command, path, remote string) error {
+	opCtx, cancel := opContext(cmd.Context())
+	defer cancel()
+	status, err := git.StatusContext(opCtx, path, remote)
+	if err != nil {
+		return fmt.Errorf("failed to get status: %w", err)
+	}
+	if status.IsDirty {
+		return fmt.Errorf("it has uncommitted changes")
+	}
+	if status.Ahead > 0 {
+		return fmt.Errorf("it has %s", pluralize(status.Ahead, "unpushed commit", "unpushed commits"))
+	}
+	if status.NoTracking && !status.IsBare {
+		// Nothing to be ahead of, so count what no remote has
+		unpushed, err := git.UnpushedCountContext(opCtx, path)
+		if err != nil {
+			return fmt.Errorf("failed to count unpushed commits: %w", err)
+		}
+		if unpushed > 0 {
+			return fmt.Errorf("it has %s on %s", pluralize(unpushed, "unpushed commit", "unpushed commits"), status.Branch)
+		}
+	}
+	return nil
+}
+
+func init() {
+	rmCmd.Flags().BoolVar(&rmDelete, "delete", false, "Also delete the clone from disk, after confirmation")
+	rmCmd.Flags().BoolVarP(&yesFlag, "yes", "y", false, "Delete without asking for confirmation")
+	rmCmd.Flags().BoolVar(&rmForce, "force", false, "With --delete, delete even with uncommitted changes or unpushed commits")
+	rootCmd.AddCommand(rmCmd)
+}
@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oschrenk/arbol/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// runGit runs git with args in dir, failing the test on an error
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+// commitEmpty adds an empty commit to the repository at dir
+func commitEmpty(t *testing.T, dir string) {
+	t.Helper()
+	runGit(t, dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "work")
+}
+
+// initClone creates a repository at dir with a single commit on branch
+// main, cloned from a new upstream when withRemote is set
+func initClone(t *testing.T, dir string, withRemote bool) {
+	t.Helper()
+	if !withRemote {
+		runGit(t, filepath.Dir(dir), "init", "-q", "-b", "main", dir)
+		commitEmpty(t, dir)
+		return
+	}
+	upstream := t.TempDir()
+	runGit(t, upstream, "init", "-q", "-b", "main")
+	commitEmpty(t, upstream)
+	runGit(t, filepath.Dir(dir), "clone", "-q", upstream, dir)
+}
+
+func TestRmAmbiguousPath(t *testing.T) {
+	_, path := setupConfig(t, `work = [{ url = "git@github.com:co/api.git" }, { url = "git@github.com:co/web.git" }]
+`)
+	err := rmCmd.RunE(rmCmd, []string{"work"})
+	if err == nil || !strings.Contains(err.Error(), "matches 2 repos") {
+		t.Fatalf("rm work = %v, want a list of the matches", err)
+	}
+	c, err := config.LoadFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repos := c.Accounts["home"].Repos["work"]; len(repos) != 2 {
+		t.Errorf("repos = %+v, want both kept", repos)
+	}
+}
+
+func TestRmKeepsClone(t *testing.T) {
+	root, path := setupConfig(t, `work = [{ url = "git@github.com:co/api.git" }]
+`)
+	prevDelete := rmDelete
+	t.Cleanup(func() { rmDelete = prevDelete })
+	rmDelete = false
+	clone := filepath.Join(root, "work", "api")
+	cloneDir(t, clone)
+
+	if err := rmCmd.RunE(rmCmd, []string{"work.api"}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := config.LoadFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repos := c.Accounts["home"].Repos["work"]; len(repos) != 0 {
+		t.Errorf("repos = %+v, want api removed", repos)
+	}
+	if _, err := os.Stat(filepath.Join(clone, ".git")); err != nil {
+		t.Errorf("clone was deleted without --delete: %v", err)
+	}
+}
+
+func TestCheckDeletable(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	check := func(dir string) error {
+		t.Helper()
+		return checkDeletable(cmd, dir, "origin")
+	}
+
+	clean := filepath.Join(t.TempDir(), "clean")
+	initClone(t, clean, true)
+	if err := check(clean); err != nil {
+		t.Errorf("clean clone: %v, want deletable", err)
+	}
+
+	dirty := filepath.Join(t.TempDir(), "dirty")
+	initClone(t, dirty, true)
+	if err := os.WriteFile(filepath.Join(dirty, "notes.txt"), []byte("wip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := check(dirty); err == nil || !strings.Contains(err.Error(), "uncommitted changes") {
+		t.Errorf("dirty clone: %v, want uncommitted changes", err)
+	}
+
+	ahead := filepath.Join(t.TempDir(), "ahead")
+	initClone(t, ahead, true)
+	commitEmpty(t, ahead)
+	if err := check(ahead); err == nil || !strings.Contains(err.Error(), "1 unpushed commit") {
+		t.Errorf("clone ahead of origin: %v, want 1 unpushed commit", err)
+	}
+
+	// With no remote-tracking branch, every commit is only here
+	local := filepath.Join(t.TempDir(), "local")
+	initClone(t, local, false)
+	if err := check(local); err == nil || !strings.Contains(err.Error(), "1 unpushed commit on main") {
+		t.Errorf("repo without a remote: %v, want 1 unpushed commit", err)
+	}
+}
@@ -1,15 +1,43 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/oschrenk/arbol/internal/config"
+	"github.com/oschrenk/arbol/internal/git"
 	"github.com/spf13/cobra"
 )
 
+// errInterrupted is returned by commands that already reported an
+// interruption (e.g. with a partial summary), so Execute only sets the exit
+// code
+var errInterrupted = errors.New("interrupted")
+
+// exitCodeError makes Execute exit with code without printing anything,
+// for commands whose exit status is part of their output
+type exitCodeError struct {
+	code int
+}
+
+func (e exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
 var (
 	accountFlag string
+	configFlag  string
+	timeoutFlag time.Duration
+	verboseFlag bool
 	cfg         *config.Config
 )
 
@@ -21,29 +49,47 @@ using a declarative TOML configuration.
 
 Define repositories in a config file, organize them in a tree structure,
 and use accounts as machine profiles.`,
+	// Execute prints errors itself, once
+	SilenceErrors: true,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		// Skip config loading for these commands
+		// Flags and args are valid by now, so later errors are runtime
+		// failures where a usage dump is just noise
+		cmd.SilenceUsage = true
+
+		if verboseFlag {
+			git.SetTrace(os.Stderr)
+		}
+
+		// Skip config loading for these commands. Cobra's completion
+		// requests load the config themselves, after --config is parsed.
 		switch cmd.Name() {
-		case "init", "completion", "version":
+		case "init", "import", "completion", "shell-init", "version", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+			return nil
+		}
+		// These read the config file themselves, if at all
+		if cmd == configPathCmd || cmd == configValidateCmd || cmd == cacheClearCmd {
 			return nil
 		}
 
 		// Load config
 		var err error
-		cfg, err = config.Load()
+		cfg, err = loadConfig()
 		if err != nil {
 			return err
 		}
-		return nil
+		return applySettings(cmd, cfg.Settings)
 	},
 }
 
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&accountFlag, "account", "a", "", "Use specific account instead of default")
+	rootCmd.PersistentFlags().StringVar(&configFlag, "config", "", "Path to config file (default $XDG_CONFIG_HOME/arbol/config.toml)")
+	rootCmd.PersistentFlags().BoolVarP(&verboseFlag, "verbose", "v", false, "Log every git command to stderr, with git's stderr when it fails")
+	rootCmd.PersistentFlags().DurationVar(&timeoutFlag, "timeout", 60*time.Second, "Timeout per git operation (0 disables)")
 
 	// Register custom completion for --account flag
 	rootCmd.RegisterFlagCompletionFunc("account", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		cfg, err := config.Load()
+		cfg, err := loadConfig()
 		if err != nil {
 			return nil, cobra.ShellCompDirectiveNoFileComp
 		}
@@ -51,22 +97,294 @@ func init() {
 	})
 }
 
-// Execute runs the root command
+// Execute runs the root command. Ctrl-C cancels the command's context,
+// which stops outstanding git operations.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		var exitErr exitCodeError
+		switch {
+		case errors.As(err, &exitErr):
+			os.Exit(exitErr.code)
+		case errors.Is(err, errInterrupted):
+			os.Exit(130)
+		case errors.Is(err, context.Canceled):
+			fmt.Fprintln(os.Stderr, "interrupted")
+			os.Exit(130)
+		}
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
 
+// configPath returns the config file to use: --config if given, otherwise
+// the default location
+func configPath() string {
+	if configFlag != "" {
+		return config.ExpandPath(configFlag)
+	}
+	return config.ConfigPath()
+}
+
+// loadConfig loads the config file selected by configPath
+func loadConfig() (*config.Config, error) {
+	return config.LoadFromPath(configPath())
+}
+
+// applySettings makes the [settings] values the defaults of cmd's flags: a
+// flag given on the command line wins over a setting, which wins over the
+// built-in default. Settings for flags cmd doesn't have are ignored.
+func applySettings(cmd *cobra.Command, settings config.Settings) error {
+	for name, value := range settings.FlagDefaults() {
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil || flag.Changed {
+			continue
+		}
+		if err := flag.Value.Set(value); err != nil {
+			return fmt.Errorf("invalid config: settings.%s: %w", strings.ReplaceAll(name, "-", "_"), err)
+		}
+	}
+	return nil
+}
+
+// opContext derives the context for a single git operation, bounded by
+// --timeout so one unreachable host can't block the whole run
+func opContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeoutFlag <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeoutFlag)
+}
+
+// accountEnv names the environment variable that selects an account when
+// --account isn't given
+const accountEnv = "ARBOL_ACCOUNT"
+
 // getAccount returns the account to use based on flags or default
 func getAccount() (*config.Account, string, error) {
-	if accountFlag != "" {
-		account, err := cfg.GetAccount(accountFlag)
+	account, name, _, err := resolveAccount(cfg)
+	return account, name, err
+}
+
+// resolveAccount picks the account from c for this invocation: the
+// --account flag, the ARBOL_ACCOUNT environment variable, this machine's
+// hostname, then the config's default. See config.ResolveAccount.
+func resolveAccount(c *config.Config) (*config.Account, string, config.AccountReason, error) {
+	hostname, _ := os.Hostname()
+	return c.ResolveAccount(accountFlag, os.Getenv(accountEnv), hostname)
+}
+
+// checkRoot returns the account's expanded root directory and whether it
+// exists. A root that exists but isn't a directory is an error.
+func checkRoot(account *config.Account, accountName string) (string, bool, error) {
+	root := config.ExpandPath(account.Root)
+	info, err := os.Stat(root)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return root, false, nil
+	case err != nil:
+		return root, false, fmt.Errorf("failed to read root of account '%s': %w", accountName, err)
+	case !info.IsDir():
+		return root, false, fmt.Errorf("root %s of account '%s' is not a directory", root, accountName)
+	}
+	return root, true, nil
+}
+
+// expandPathArg resolves an abbreviated path argument (see
+// config.Account.ExpandAbbrev). Globs are checked and passed through. An
+// abbreviation matching nothing is returned
+// as is so the caller reports it like any unknown path; one matching several
+// paths is an error listing them.
+func expandPathArg(account *config.Account, accountName, arg string) (string, error) {
+	if err := config.CheckFilter(arg); err != nil {
+		return "", err
+	}
+	matches := account.ExpandAbbrev(arg)
+	switch len(matches) {
+	case 0:
+		return arg, nil
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("'%s' is ambiguous in account '%s':\n  %s",
+		arg, accountName, strings.Join(matches, "\n  "))
+}
+
+// excludeFlags holds --exclude patterns for commands taking path arguments
+var excludeFlags []string
+
+// includeDisabledFlag makes selectRepos keep repos with enabled = false
+var includeDisabledFlag bool
+
+// tagFlags holds --tag filters; repos need all of them, or one with
+// --tag-any
+var (
+	tagFlags   []string
+	tagAnyFlag bool
+)
+
+// selectRepos returns the union of the repos selected by each path argument
+// (after expanding abbreviations), minus those matching --exclude or lacking
+// the --tag tags, without duplicates and sorted by dotted path. Repos that
+// don't apply on this machine are left out, disabled ones only without
+// --include-disabled. No arguments select every repo. The expanded filters
+// are returned for messages and further filtering.
+func selectRepos(account *config.Account, accountName string, args []string) ([]config.RepoWithPath, []string, error) {
+	var filters []string
+	for _, arg := range args {
+		filter, err := expandPathArg(account, accountName, arg)
 		if err != nil {
-			return nil, "", err
+			return nil, nil, err
+		}
+		filters = append(filters, filter)
+	}
+
+	for _, exclude := range excludeFlags {
+		if err := config.CheckFilter(exclude); err != nil {
+			return nil, nil, err
 		}
-		return account, accountFlag, nil
 	}
-	return cfg.DefaultAccount()
+
+	var repos []config.RepoWithPath
+	for _, repo := range matchingRepos(account, filters) {
+		if skipReason(repo) == "" {
+			repos = append(repos, repo)
+		}
+	}
+	return repos, filters, nil
+}
+
+// skippedRepo is a repo selectRepos left out because it doesn't apply on
+// this machine
+type skippedRepo struct {
+	config.RepoWithPath
+	Reason string
+}
+
+// skippedRepos returns the repos matching filters (as returned by
+// selectRepos) that selectRepos skipped, with the reason
+func skippedRepos(account *config.Account, filters []string) []skippedRepo {
+	var skipped []skippedRepo
+	for _, repo := range matchingRepos(account, filters) {
+		if reason := skipReason(repo); reason != "" {
+			skipped = append(skipped, skippedRepo{RepoWithPath: repo, Reason: reason})
+		}
+	}
+	return skipped
+}
+
+// skipReason returns why repo doesn't apply on this machine, or "" when it
+// does. --include-disabled makes disabled repos apply.
+func skipReason(repo config.RepoWithPath) string {
+	hostname, _ := os.Hostname()
+	reason := repo.Repo.SkipReason(runtime.GOOS, hostname)
+	if reason == config.SkipDisabled && includeDisabledFlag {
+		return ""
+	}
+	return reason
+}
+
+// matchingRepos returns every configured repo matching one of filters (all
+// repos for none), minus --exclude and --tag, without duplicates and sorted
+// by dotted path
+func matchingRepos(account *config.Account, filters []string) []config.RepoWithPath {
+	if len(filters) == 0 {
+		filters = []string{""}
+	}
+
+	var repos []config.RepoWithPath
+	seen := make(map[string]bool)
+	for _, filter := range filters {
+		for _, repo := range account.GetAllRepos(filter) {
+			if seen[repo.FullPath] || excluded(repo.Path+"."+repo.Name) || !repo.Repo.HasTags(tagFlags, tagAnyFlag) {
+				continue
+			}
+			seen[repo.FullPath] = true
+			repos = append(repos, repo)
+		}
+	}
+
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].Path+"."+repos[i].Name < repos[j].Path+"."+repos[j].Name
+	})
+	return repos
+}
+
+// excluded reports whether the dotted id matches an --exclude pattern
+func excluded(id string) bool {
+	for _, exclude := range excludeFlags {
+		if config.MatchesPath(id, exclude) {
+			return true
+		}
+	}
+	return false
+}
+
+// describeSelection describes what path filters and --tag selected, for
+// "No repos found ..." messages: "matching 'work' tagged 'ci'". It's empty
+// when everything was selected.
+func describeSelection(filters []string) string {
+	var parts []string
+	if len(filters) > 0 {
+		parts = append(parts, "matching "+quoteFilters(filters))
+	}
+	if len(tagFlags) > 0 {
+		join := " and "
+		if tagAnyFlag {
+			join = " or "
+		}
+		quoted := make([]string, len(tagFlags))
+		for i, tag := range tagFlags {
+			quoted[i] = "'" + tag + "'"
+		}
+		parts = append(parts, "tagged "+strings.Join(quoted, join))
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteFilters formats path filters for messages: 'work', 'personal'
+func quoteFilters(filters []string) string {
+	quoted := make([]string, len(filters))
+	for i, filter := range filters {
+		quoted[i] = "'" + filter + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// resolveRepo returns the single repo that path selects in account. A path
+// matching several repos is an error that lists them, so commands acting on
+// one repo never pick one arbitrarily.
+func resolveRepo(account *config.Account, accountName, path string) (config.RepoWithPath, error) {
+	return resolveRepoIn(account, accountName, path, account.GetRepos)
+}
+
+// resolveConfigRepo is like resolveRepo but also considers repos that don't
+// apply on this machine, for commands that edit the config
+func resolveConfigRepo(account *config.Account, accountName, path string) (config.RepoWithPath, error) {
+	return resolveRepoIn(account, accountName, path, account.GetAllRepos)
+}
+
+func resolveRepoIn(account *config.Account, accountName, path string, getRepos func(string) []config.RepoWithPath) (config.RepoWithPath, error) {
+	path, err := expandPathArg(account, accountName, path)
+	if err != nil {
+		return config.RepoWithPath{}, err
+	}
+
+	repos := getRepos(path)
+	switch len(repos) {
+	case 0:
+		return config.RepoWithPath{}, fmt.Errorf("no repos found matching '%s' in account '%s'", path, accountName)
+	case 1:
+		return repos[0], nil
+	}
+
+	ids := make([]string, len(repos))
+	for i, repo := range repos {
+		ids[i] = repo.Path + "." + repo.Name
+	}
+	sort.Strings(ids)
+	return config.RepoWithPath{}, fmt.Errorf("'%s' matches %d repos in account '%s':\n  %s",
+		path, len(repos), accountName, strings.Join(ids, "\n  "))
 }
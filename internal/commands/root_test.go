@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oschrenk/arbol/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func TestResolveAccountPrecedence(t *testing.T) {
+	c := &config.Config{
+		Accounts: map[string]*config.Account{
+			"home": {Default: true},
+			"work": {},
+			"ci":   {},
+		},
+	}
+	prev := accountFlag
+	defer func() { accountFlag = prev }()
+
+	cases := []struct {
+		name string
+		flag string
+		env  string
+		want string
+	}{
+		{"config default", "", "", "home"},
+		{"env over default", "", "work", "work"},
+		{"flag over env", "ci", "work", "ci"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			accountFlag = tc.flag
+			t.Setenv(accountEnv, tc.env)
+			_, name, _, err := resolveAccount(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != tc.want {
+				t.Errorf("resolveAccount() = %q, want %q", name, tc.want)
+			}
+		})
+	}
+
+	accountFlag = ""
+	t.Setenv(accountEnv, "missing")
+	if _, _, _, err := resolveAccount(c); err == nil || err.Error() != "account 'missing' not found" {
+		t.Errorf("expected account not found error for invalid env value, got %v", err)
+	}
+}
+
+func TestApplySettings(t *testing.T) {
+	var width, retries int
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().IntVar(&width, "path-width", 0, "")
+	cmd.Flags().IntVar(&retries, "retries", 2, "")
+	if err := cmd.ParseFlags([]string{"--retries", "5"}); err != nil {
+		t.Fatal(err)
+	}
+
+	thirty, zero := 30, 0
+	settings := config.Settings{PathWidth: &thirty, Retries: &zero, Fields: "path"}
+	if err := applySettings(cmd, settings); err != nil {
+		t.Fatal(err)
+	}
+	if width != 30 {
+		t.Errorf("path-width = %d, want the setting 30", width)
+	}
+	if retries != 5 {
+		t.Errorf("retries = %d, want the explicit flag 5", retries)
+	}
+}
+
+func TestCheckRoot(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name       string
+		root       string
+		wantExists bool
+		wantErr    bool
+	}{
+		{"exists", dir, true, false},
+		{"missing", filepath.Join(dir, "missing"), false, false},
+		{"not a directory", file, false, true},
+	}
+	for _, c := range cases {
+		root, exists, err := checkRoot(&config.Account{Root: c.root}, "a")
+		if root != c.root || exists != c.wantExists || (err != nil) != c.wantErr {
+			t.Errorf("%s: checkRoot() = %q, %v, %v, want exists %v, error %v", c.name, root, exists, err, c.wantExists, c.wantErr)
+		}
+	}
+}
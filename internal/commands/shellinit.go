@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// The wrappers complete paths through cobra's hidden __completeNoDesc
+// command, which runs the same completion as `arbol which <TAB>`. Its last
+// line is the ":<directive>" marker, filtered out here.
+
+const bashInit = `arbol-cd() {
+  local dir
+  dir="$(command arbol which "$@")" || return
+  builtin cd "$dir"
+}
+
+_arbol_cd() {
+  local cur="${COMP_WORDS[COMP_CWORD]}"
+  COMPREPLY=($(compgen -W "$(command arbol __completeNoDesc which "$cur" 2>/dev/null | command grep -v '^:')" -- "$cur"))
+}
+complete -F _arbol_cd arbol-cd
+`
+
+const zshInit = `arbol-cd() {
+  local dir
+  dir="$(command arbol which "$@")" || return
+  builtin cd "$dir"
+}
+
+_arbol_cd() {
+  local -a paths
+  paths=(${(f)"$(command arbol __completeNoDesc which "${words[CURRENT]}" 2>/dev/null | command grep -v '^:')"})
+  compadd -a paths
+}
+(( $+functions[compdef] )) && compdef _arbol_cd arbol-cd
+`
+
+const fishInit = `function arbol-cd --description 'Change into an arbol repository'
+    set -l dir (command arbol which $argv); or return
+    builtin cd $dir
+end
+
+complete -c arbol-cd -f -a '(command arbol __completeNoDesc which (commandline -ct) 2>/dev/null | string match -v ":*")'
+`
+
+var shellInitCmd = &cobra.Command{
+	Use:   "shell-init [bash|zsh|fish]",
+	Short: "Print shell integration",
+	Long: `Print an arbol-cd shell function that changes into a repository, using
+arbol which, with tab completion for repo paths.
+
+Add it to your shell's rc file:
+
+Bash (~/.bashrc):
+  eval "$(arbol shell-init bash)"
+
+Zsh (~/.zshrc, after compinit):
+  eval "$(arbol shell-init zsh)"
+
+Fish (~/.config/fish/config.fish):
+  arbol shell-init fish | source
+
+Then:
+  arbol-cd work.backend.api`,
+	DisableFlagsInUseLine: true,
+	ValidArgs:             []string{"bash", "zsh", "fish"},
+	Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	Run: func(cmd *cobra.Command, args []string) {
+		switch args[0] {
+		case "bash":
+			fmt.Print(bashInit)
+		case "zsh":
+			fmt.Print(zshInit)
+		case "fish":
+			fmt.Print(fishInit)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(shellInitCmd)
+}
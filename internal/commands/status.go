@@ -1,26 +1,59 @@
 package commands
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
-	"sort"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"github.com/oschrenk/arbol/internal/config"
 	"github.com/oschrenk/arbol/internal/git"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var (
-	noColor     bool
-	noHeaders   bool
-	pathWidth   int
-	branchWidth int
-	plainOutput bool
+	noColor       bool
+	noHeaders     bool
+	pathWidth     int
+	branchWidth   int
+	plainOutput   bool
+	showUntracked bool
+	showSkipped   bool
+	exitCode      bool
+	onlyChanges   bool
+	showSummary   bool
+	formatFlag    string
+	watchFlag     bool
+	watchInterval time.Duration
+	longFlag      bool
+	fieldsFlag    string
+	statusFetch   bool
+	statusJobs    int
+	noRemoteFlag  bool
+	streamFlag    bool
+	ndjsonFlag    bool
+	pathsOnly     bool
+	displayFlag   string
 )
 
+// Values of --display, how the PATH column names a repo
+const (
+	displayDotted  = "dotted"  // config path, work.backend.api
+	displayRelpath = "relpath" // directory under the account root, work/backend/api
+	displayAbspath = "abspath" // full directory
+)
+
+// subjectWidth caps the SUBJECT column of --long
+const subjectWidth = 50
+
 type jsonBranch struct {
 	Name     string `json:"name"`
 	Detached bool   `json:"detached"`
@@ -30,21 +63,36 @@ type jsonChanges struct {
 	Dirty      bool   `json:"dirty"`
 	Files      int    `json:"files"`
 	LastCommit string `json:"last_commit"`
+	Author     string `json:"last_commit_author,omitempty"`
+	Subject    string `json:"last_commit_subject,omitempty"`
+	Operation  string `json:"operation,omitempty"`
 }
 
 type jsonRemote struct {
-	Ahead    int  `json:"ahead"`
-	Behind   int  `json:"behind"`
-	Diverged bool `json:"diverged"`
-	Tracking bool `json:"tracking"`
+	Name        string `json:"name"`
+	Ahead       int    `json:"ahead"`
+	Behind      int    `json:"behind"`
+	Diverged    bool   `json:"diverged"`
+	Tracking    bool   `json:"tracking"`
+	URL         string `json:"url,omitempty"`
+	URLMismatch bool   `json:"url_mismatch"`
+	LastFetch   string `json:"last_fetch,omitempty"`
+	FetchError  string `json:"fetch_error,omitempty"`
+	Skipped     bool   `json:"skipped,omitempty"` // ahead/behind not computed (--no-remote)
 }
 
 type jsonRepo struct {
-	ID      string       `json:"id"`
-	Path    string       `json:"path"`
-	Branch  *jsonBranch  `json:"branch,omitempty"`
-	Changes *jsonChanges `json:"changes,omitempty"`
-	Remote  *jsonRemote  `json:"remote,omitempty"`
+	ID        string       `json:"id"`
+	Path      string       `json:"path"`
+	Tags      []string     `json:"tags,omitempty"`
+	Disabled  bool         `json:"disabled,omitempty"`
+	Skipped   string       `json:"skipped,omitempty"` // why it doesn't apply here, with --show-skipped
+	Unmanaged bool         `json:"unmanaged,omitempty"`
+	Bare      bool         `json:"bare,omitempty"`
+	Mirror    bool         `json:"mirror,omitempty"` // mirror = true in the config
+	Branch    *jsonBranch  `json:"branch,omitempty"`
+	Changes   *jsonChanges `json:"changes,omitempty"`
+	Remote    *jsonRemote  `json:"remote,omitempty"`
 }
 
 // ANSI color codes
@@ -59,36 +107,140 @@ const (
 )
 
 var statusCmd = &cobra.Command{
-	Use:   "status [path]",
+	Use:   "status [path...]",
 	Short: "Show status of repositories",
 	Long: `Show the status of repositories including branch and sync state.
 
 Without a path argument, shows status of all repos in the account.
-With a path, shows only repos under that path.
+With paths, shows only repos under any of them.
+
+Paths may be globs matched against the dotted path ('work.*'); quote them
+so the shell doesn't expand them. Use --exclude (repeatable) to drop repos
+matching a path or glob.
+
+Use --fetch to fetch each cloned repo first, so ahead/behind reflect the
+remote as it is now. Fetches run --jobs at a time, each just before its
+repo's status is read. A repo whose fetch fails is still shown, noted as
+"fetch failed".
+
+Use --only-changes to hide repos that are cloned, clean, and level with their
+tracking branch; not cloned, unreadable, detached, and out-of-sync repos are
+still shown.
+
+Use --summary to end the table with a line counting repos by state.
+
+Use --format markdown to print the table as GitHub-flavored Markdown, e.g.
+for an issue or wiki page; it takes --fields, --long, and --only-changes
+like the table. Use --format with anything else to print each repo with a
+Go template instead. The template sees the repo's Path, Name, ID, FullPath,
+Repo.URL, Cloned, Error, and status fields (Branch, IsDetached, IsBare, IsDirty,
+DirtyFiles, Remote, Ahead, Behind, NoTracking, RemoteSkipped, LastCommitTime, Author,
+Subject, LastFetch, Operation, RemoteURL), plus the helpers relTime (formats
+a time like the AGE column) and pad (pads to a width).
+
+The table's symbols (● ✔ ↑ ↓ …) become ASCII (* ok ^ v ...) with --ascii,
+or when the locale (LC_ALL, LC_CTYPE, or LANG) isn't UTF-8.
+
+Use --display to name repos in the PATH column by their dotted config path
+(dotted, the default), their directory relative to the account root
+(relpath), or their full directory (abspath), e.g. to copy one to cd into.
+
+Use --long to add the last commit's author and subject. Use --fields to pick
+the table columns and their order from: path, branch, work, remote, ahead,
+behind, age, fetched, author, subject, comments.
+
+Use --watch to redraw the table every --interval (default 5s) until Ctrl-C.
+Rows of repos that changed since the previous redraw (switched branch, got
+dirty, moved ahead or behind, ...) are marked » for one redraw.
+
+Use --stream to print the table a row at a time as each repo's status is
+read, in the order they finish, so one slow repo doesn't hold back the
+rest. Columns have fixed widths: PATH fits the selected repos, BRANCH is
+--branch-width (default 20), and a longer cell shifts its row.
+
+Use --ndjson to print newline-delimited JSON instead of an array: one line
+per repo as soon as its status is read, in the order they finish, each the
+object the array would hold. Consumers like 'jq -c' or a dashboard can then
+react to each repo without waiting for the slowest one.
+
+Use --paths-only to print just the repos' paths, one per line, named as
+--display says, for piping into fzf or xargs. No git status is read at all,
+so it's instant however many repos there are.
+
+Use --notify to also send a desktop notification like "3 repos behind, 1
+dirty" when any repo is behind or dirty, and none otherwise, so it can run
+from a timer. The notify_command setting replaces the platform's notifier.
+
+Use --exit-code to exit with a non-zero status when repos need attention.
+The code combines bits: 1 dirty, 2 not cloned, 4 ahead/behind/no tracking
+branch, 8 status could not be read.
+
+Use --show-untracked to also list git repos under the account root that
+aren't in the config.
 
 Examples:
   arbol status                  # status of all repos
   arbol status work.backend     # status of repos under work.backend
-  arbol status --account spare  # use specific account`,
-	Args: cobra.MaximumNArgs(1),
+  arbol status work personal    # status of repos under work and personal
+  arbol status 'work.*'         # glob, quoted so the shell doesn't expand it
+  arbol status --exclude personal.archive
+  arbol status --account spare  # use specific account
+  arbol status --show-untracked # also list repos missing from the config
+  arbol status --format '{{.ID}} {{.Branch}} {{.Ahead}}/{{.Behind}}'
+  arbol status --plain --fields path,branch,ahead,behind,age,author`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		account, accountName, err := getAccount()
+		view, err := newStatusView()
 		if err != nil {
 			return err
 		}
 
-		pathFilter := ""
-		if len(args) > 0 {
-			pathFilter = args[0]
+		if ndjsonFlag {
+			switch {
+			case plainOutput && cmd.Flags().Changed("plain"):
+				return fmt.Errorf("--ndjson can't be combined with --plain")
+			case view.tmpl != nil || view.markdown:
+				return fmt.Errorf("--ndjson can't be combined with --format")
+			case watchFlag:
+				return fmt.Errorf("--ndjson can't be combined with --watch")
+			case streamFlag:
+				return fmt.Errorf("--ndjson can't be combined with --stream")
+			}
+			// Over plain = true in [settings]
+			plainOutput = false
+		}
+		if pathsOnly {
+			if name := changedFlag(cmd, "plain", "format", "watch", "stream", "ndjson", "fetch", "only-changes",
+				"exit-code", "notify", "show-untracked", "show-skipped"); name != "" {
+				return fmt.Errorf("--paths-only can't be combined with --%s", name)
+			}
+			// Messages go to stderr, so they don't reach a pipe
+			plainOutput = false
+		}
+
+		git.SetMaxConcurrency(statusJobs)
+
+		account, accountName, err := getAccount()
+		if err != nil {
+			return err
 		}
 
-		repos := account.GetRepos(pathFilter)
-		if len(repos) == 0 {
-			if pathFilter != "" {
+		repos, filters, err := selectRepos(account, accountName, args)
+		if err != nil {
+			return err
+		}
+		if pathsOnly && len(repos) > 0 {
+			return writePaths(os.Stdout, repos)
+		}
+		var skipped []skippedRepo
+		if showSkipped {
+			skipped = skippedRepos(account, filters)
+		}
+		if len(repos) == 0 && len(skipped) == 0 {
+			if selection := describeSelection(filters); selection != "" {
 				if plainOutput {
-					fmt.Printf("No repos found matching '%s' in account '%s'\n", pathFilter, accountName)
+					fmt.Printf("No repos found %s in account '%s'\n", selection, accountName)
 				} else {
-					return fmt.Errorf("no repos found matching '%s' in account '%s'", pathFilter, accountName)
+					return fmt.Errorf("no repos found %s in account '%s'", selection, accountName)
 				}
 			} else {
 				if plainOutput {
@@ -100,17 +252,79 @@ Examples:
 			return nil
 		}
 
-		// Sort repos by display path for consistent output
-		sort.Slice(repos, func(i, j int) bool {
-			pathI := repos[i].Path + "." + repos[i].Name
-			pathJ := repos[j].Path + "." + repos[j].Name
-			return pathI < pathJ
-		})
+		// Without the root every repo would be a "not cloned" row
+		root, rootExists, err := checkRoot(account, accountName)
+		if err != nil {
+			return err
+		}
+		if !rootExists {
+			if !plainOutput {
+				return fmt.Errorf("root %s of account '%s' does not exist, run 'arbol sync' to clone its repos", root, accountName)
+			}
+			fmt.Printf("Root %s of account '%s' does not exist, so none of its repos are cloned\n", root, accountName)
+			fmt.Println("Run 'arbol sync' to clone them, or fix root in the config")
+			if exitCode && len(repos) > 0 {
+				return exitCodeError{code: exitNotCloned}
+			}
+			return nil
+		}
+
+		var unmanaged []unmanagedRepo
+		if showUntracked {
+			unmanaged, err = findUnmanaged(account, filters)
+			if err != nil {
+				return err
+			}
+		}
+
+		if watchFlag {
+			if notifyFlag {
+				return fmt.Errorf("--notify can't be combined with --watch")
+			}
+			if streamFlag {
+				return fmt.Errorf("--stream can't be combined with --watch")
+			}
+			return watchStatus(cmd.Context(), repos, unmanaged, skipped, view)
+		}
+
+		var states []repoState
+		if ndjsonFlag {
+			if states, err = ndjsonStatus(cmd.Context(), repos, unmanaged, skipped); err != nil {
+				return err
+			}
+		} else if streamFlag {
+			if view.tmpl != nil || view.markdown {
+				return fmt.Errorf("--stream can't be combined with --format")
+			}
+			if states, err = streamStatus(cmd.Context(), repos, unmanaged, skipped, view); err != nil {
+				return err
+			}
+		} else {
+			if states, err = gatherStatus(cmd.Context(), repos); err != nil {
+				return err
+			}
+			if err := renderStatus(states, unmanaged, skipped, view); err != nil {
+				return err
+			}
+		}
+
+		bits := 0
+		for _, state := range states {
+			bits |= state.bits()
+		}
+
+		if notifyFlag {
+			if message := notifyMessage(states); message != "" {
+				if err := notify(cmd.Context(), "arbol: "+accountName, message); err != nil {
+					return err
+				}
+			}
+		}
 
-		if plainOutput {
-			return printPlainStatus(repos)
+		if exitCode && bits != 0 {
+			return exitCodeError{code: bits}
 		}
-		return printJSONStatus(repos)
+		return nil
 	},
 	ValidArgsFunction: completeRepoPath,
 }
@@ -119,78 +333,572 @@ func init() {
 	statusCmd.Flags().BoolVar(&plainOutput, "plain", false, "Show table output instead of JSON")
 	statusCmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colored output (only with --plain)")
 	statusCmd.Flags().BoolVar(&noHeaders, "no-headers", false, "Hide column headers (only with --plain)")
-	statusCmd.Flags().IntVar(&pathWidth, "path-width", 30, "Width of PATH column (only with --plain)")
-	statusCmd.Flags().IntVar(&branchWidth, "branch-width", 15, "Width of BRANCH column (only with --plain)")
+	statusCmd.Flags().IntVar(&pathWidth, "path-width", 0, "Truncate PATH column to N characters, 0 fits the content (only with --plain)")
+	statusCmd.Flags().IntVar(&branchWidth, "branch-width", 0, "Truncate BRANCH column to N characters, 0 fits the content (only with --plain)")
+	statusCmd.Flags().BoolVar(&exitCode, "exit-code", false, "Exit non-zero when repos need attention (bits: 1 dirty, 2 not cloned, 4 out of sync, 8 error)")
+	statusCmd.Flags().BoolVar(&notifyFlag, "notify", false, "Send a desktop notification when repos are behind or dirty")
+	statusCmd.Flags().BoolVar(&showSkipped, "show-skipped", false, "Also list configured repos left out on this machine, and why")
+	statusCmd.Flags().BoolVar(&showUntracked, "show-untracked", false, "Also list repos on disk that aren't in the config")
+	statusCmd.Flags().BoolVar(&onlyChanges, "only-changes", false, "Hide repos that are cloned, clean, and in sync")
+	statusCmd.Flags().BoolVar(&showSummary, "summary", false, "End with a line counting repos by state (only with --plain)")
+	statusCmd.Flags().StringVar(&formatFlag, "format", "", "Print each repo with a Go template, or 'markdown' for a Markdown table")
+	statusCmd.Flags().BoolVar(&streamFlag, "stream", false, "Print table rows as repos finish, in that order, with fixed column widths")
+	statusCmd.Flags().BoolVar(&ndjsonFlag, "ndjson", false, "Print a line of JSON per repo as its status is read, instead of an array")
+	statusCmd.Flags().BoolVar(&pathsOnly, "paths-only", false, "Print only the repos' paths, one per line, without reading any status")
+	statusCmd.Flags().BoolVar(&watchFlag, "watch", false, "Redraw the status table periodically until interrupted")
+	statusCmd.Flags().DurationVar(&watchInterval, "interval", 5*time.Second, "Time between redraws with --watch")
+	statusCmd.Flags().BoolVar(&longFlag, "long", false, "Also show the last commit's author and subject")
+	statusCmd.Flags().BoolVar(&asciiFlag, "ascii", false, "Use ASCII symbols instead of Unicode ones (only with --plain)")
+	statusCmd.Flags().StringVar(&displayFlag, "display", displayDotted, "How the PATH column names repos: dotted, relpath, or abspath (only with --plain)")
+	statusCmd.Flags().StringVar(&fieldsFlag, "fields", "", "Comma-separated table columns, e.g. path,branch,ahead,behind,age (only with --plain)")
+	statusCmd.Flags().BoolVar(&noRemoteFlag, "no-remote", false, "Skip comparing branches with their remote, for a faster dirty/branch overview")
+	statusCmd.Flags().BoolVar(&cacheFlag, "cache", false, "Reuse the cached status of repos whose HEAD, index, and refs haven't changed")
+	statusCmd.Flags().BoolVar(&noCacheFlag, "no-cache", false, "Read every repo afresh, ignoring cache = true in [settings]")
+	statusCmd.Flags().IntVarP(&statusJobs, "jobs", "j", 8, "Read the status of up to N repos at once")
+	statusCmd.Flags().BoolVar(&statusFetch, "fetch", false, "Fetch each cloned repo before reading its status")
+	statusCmd.Flags().StringArrayVar(&excludeFlags, "exclude", nil, "Skip repos matching this path or glob (repeatable)")
+	statusCmd.Flags().StringArrayVar(&tagFlags, "tag", nil, "Only repos with this tag (repeatable, all must match)")
+	statusCmd.Flags().BoolVar(&tagAnyFlag, "tag-any", false, "With several --tag, select repos with any of them")
+	statusCmd.Flags().BoolVar(&includeDisabledFlag, "include-disabled", false, "Also select repos with enabled = false")
 	rootCmd.AddCommand(statusCmd)
 }
 
-func printPlainStatus(repos []config.RepoWithPath) error {
-	const workWidth = 5
-	const remoteWidth = 8
-	const ageWidth = 6
+// statusView is how gathered states are rendered, resolved from the output
+// flags once up front so mistakes fail before any git work
+type statusView struct {
+	tmpl     *template.Template // --format template, nil for a table or JSON
+	markdown bool               // --format markdown
+	fields   []statusField      // table columns
+	changed  map[string]bool    // --watch: ids of repos changed since the last redraw, nil outside a watch
+	width    int                // --watch: terminal columns to fit the table in, 0 for no limit
+}
 
-	if !noHeaders {
-		fmt.Printf("%-*s  %-*s  %-*s  %-*s  %-*s  %s\n",
-			pathWidth, "PATH",
-			branchWidth, "BRANCH",
-			workWidth, "WORK",
-			remoteWidth, "REMOTE",
-			ageWidth, "AGE",
-			"COMMENTS")
+func newStatusView() (statusView, error) {
+	var view statusView
+	switch displayFlag {
+	case displayDotted, displayRelpath, displayAbspath:
+	default:
+		return view, fmt.Errorf("invalid --display '%s' (valid: %s, %s, %s)", displayFlag, displayDotted, displayRelpath, displayAbspath)
+	}
+	if statusJobs < 1 {
+		return view, fmt.Errorf("--jobs must be at least 1")
+	}
+	glyphs = unicodeGlyphs
+	if useASCII(asciiFlag, os.Getenv) {
+		glyphs = asciiGlyphs
+	}
+	if formatFlag == formatMarkdown {
+		view.markdown = true
+	} else if formatFlag != "" {
+		tmpl, err := parseFormat(formatFlag)
+		if err != nil {
+			return view, err
+		}
+		view.tmpl = tmpl
 	}
 
-	for _, repo := range repos {
-		displayPath := repo.Path + "." + repo.Name
-		printRepoStatus(displayPath, repo.FullPath, pathWidth, branchWidth, workWidth, remoteWidth, ageWidth)
+	spec := fieldsFlag
+	if spec == "" {
+		spec = defaultFields
+		if longFlag {
+			spec = longFields
+		}
+	}
+	fields, err := parseFields(spec)
+	if err != nil {
+		return view, err
+	}
+	view.fields = fields
+	return view, nil
+}
+
+// renderStatus prints gathered states in the selected output: the --format
+// template, the --plain table, or JSON
+func renderStatus(states []repoState, unmanaged []unmanagedRepo, skipped []skippedRepo, view statusView) error {
+	summary := statusSummary(states)
+
+	hidden := 0
+	if onlyChanges {
+		var shown []repoState
+		for _, state := range states {
+			if state.upToDate() {
+				hidden++
+				continue
+			}
+			shown = append(shown, state)
+		}
+		states = shown
 	}
+
+	if view.tmpl != nil {
+		return writeFormatted(os.Stdout, view.tmpl, states)
+	}
+	if view.markdown {
+		writeMarkdown(os.Stdout, states, view.fields)
+		if showSummary {
+			fmt.Printf("\n%s\n", stripAnsi(summary))
+		}
+		return nil
+	}
+	if !plainOutput {
+		return printJSONStatus(states, unmanaged, skipped)
+	}
+
+	printPlainStatus(states, view)
+	printPlainTrailer(hidden, summary, unmanaged, skipped)
 	return nil
 }
 
-func printJSONStatus(repos []config.RepoWithPath) error {
-	var results []jsonRepo
+// printPlainTrailer ends the table with the --only-changes count, the
+// --summary line, and the untracked and skipped repos
+func printPlainTrailer(hidden int, summary string, unmanaged []unmanagedRepo, skipped []skippedRepo) {
+	if hidden > 0 || showSummary {
+		fmt.Println()
+	}
+	if hidden > 0 {
+		fmt.Printf("hidden %s\n", pluralize(hidden, "up-to-date repo", "up-to-date repos"))
+	}
+	if showSummary {
+		fmt.Println(summary)
+	}
+	printPlainUnmanaged(unmanaged)
+	printPlainSkipped(skipped)
+}
+
+// streamStatus prints the table a row at a time as repos finish, instead of
+// sorting and sizing it once everything is read. Columns get fixed widths
+// (see streamWidths), so a cell wider than its column shifts the rest of its
+// row.
+func streamStatus(ctx context.Context, repos []config.RepoWithPath, unmanaged []unmanagedRepo, skipped []skippedRepo, view statusView) ([]repoState, error) {
+	widths := streamWidths(repos, view.fields)
+	if !noHeaders {
+		writeRow(os.Stdout, headerRow(view.fields), widths)
+	}
+	hidden := 0
+	states, err := gatherStatusEach(ctx, repos, func(state repoState) {
+		if onlyChanges && state.upToDate() {
+			hidden++
+			return
+		}
+		writeRow(os.Stdout, statusRow(state, view.fields), widths)
+	})
+	if err != nil {
+		return nil, err
+	}
+	printPlainTrailer(hidden, statusSummary(states), unmanaged, skipped)
+	return states, nil
+}
+
+// streamWidths returns the column widths of a streamed table: PATH fits the
+// selected repos (up to --path-width), BRANCH is --branch-width or
+// defaultStreamBranchWidth, and the other columns are as wide as their
+// typical contents
+func streamWidths(repos []config.RepoWithPath, fields []statusField) []int {
+	widths := make([]int, len(fields))
+	for i, field := range fields {
+		width := field.streamWidth
+		switch field.name {
+		case "path":
+			for _, repo := range repos {
+				width = max(width, visibleWidth(truncate(displayedPath(repo.Path+"."+repo.Name, repo.FullPath), pathWidth)))
+			}
+		case "branch":
+			width = defaultStreamBranchWidth
+			if branchWidth > 0 {
+				width = branchWidth
+			}
+		}
+		widths[i] = max(width, len(field.header))
+	}
+	return widths
+}
+
+// defaultStreamBranchWidth is the BRANCH column width of a streamed table
+// without --branch-width
+const defaultStreamBranchWidth = 20
+
+// clearScreen moves the cursor home and clears the terminal
+const clearScreen = "\033[H\033[2J"
+
+// watchStatus redraws the status every --interval until ctx is done. It
+// always draws the table (or --format output), as JSON can't be redrawn in
+// place. Each redraw fits the table to the terminal's width, and a resize
+// (SIGWINCH) redraws it right away, without reading the repos again.
+func watchStatus(ctx context.Context, repos []config.RepoWithPath, unmanaged []unmanagedRepo, skipped []skippedRepo, view statusView) error {
+	if watchInterval <= 0 {
+		return fmt.Errorf("--interval must be positive, got %s", watchInterval)
+	}
+	plainOutput = true
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	resized, stopResize := notifyResize()
+	defer stopResize()
+	var previous map[string]watchSnapshot
+	var states []repoState
+	for refresh := true; ; {
+		if refresh {
+			var err error
+			states, err = gatherStatus(ctx, repos)
+			if ctx.Err() != nil {
+				// Ctrl-C is how a watch ends, not a failure
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			current := watchSnapshots(states)
+			view.changed = changedRepos(previous, current)
+			previous = current
+		}
+
+		view.width = terminalWidth()
+		fmt.Print(clearScreen)
+		fmt.Printf("Every %s: arbol status  %s\n\n", watchInterval, time.Now().Format("15:04:05"))
+		if err := renderStatus(states, unmanaged, skipped, view); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			refresh = true
+		case <-resized:
+			refresh = false
+		}
+	}
+}
+
+// watchSnapshot is the part of a repo's state --watch marks a row for when
+// it changes between redraws. Times that move on their own, like the last
+// fetch with --fetch, are left out.
+type watchSnapshot struct {
+	cloned     bool
+	err        string
+	branch     string
+	detached   bool
+	dirtyFiles int
+	ahead      int
+	behind     int
+	noTracking bool
+	operation  string
+	lastCommit time.Time
+}
+
+// watchSnapshots takes the snapshot of each of states, by id
+func watchSnapshots(states []repoState) map[string]watchSnapshot {
+	snapshots := make(map[string]watchSnapshot, len(states))
+	for _, state := range states {
+		snapshot := watchSnapshot{cloned: state.Cloned}
+		if state.Err != nil {
+			snapshot.err = state.Err.Error()
+		}
+		if status := state.Status; status != nil {
+			snapshot.branch, snapshot.detached = status.Branch, status.IsDetached
+			snapshot.dirtyFiles, snapshot.operation = status.DirtyFiles, status.Operation
+			snapshot.ahead, snapshot.behind, snapshot.noTracking = status.Ahead, status.Behind, status.NoTracking
+			snapshot.lastCommit = status.LastCommitTime
+		}
+		snapshots[state.Repo.Path+"."+state.Repo.Name] = snapshot
+	}
+	return snapshots
+}
+
+// changedRepos returns the ids of the repos whose snapshot differs between
+// the previous redraw and the current one. Nothing is marked on the first
+// redraw, without a previous one, but the result is never nil.
+func changedRepos(previous, current map[string]watchSnapshot) map[string]bool {
+	changed := make(map[string]bool)
+	for id, snapshot := range current {
+		if before, ok := previous[id]; ok && before != snapshot {
+			changed[id] = true
+		}
+	}
+	return changed
+}
 
+// repoState is what status gathered for one configured repo: not cloned,
+// unreadable (Err), or its Status. FetchErr is set when --fetch failed; Status
+// then reflects the previous fetch.
+type repoState struct {
+	Repo     config.RepoWithPath
+	Cloned   bool
+	Status   *git.RepoStatus
+	Err      error
+	FetchErr error
+}
+
+// gatherStatus reads the status of each repo, --jobs at a time, fetching
+// first with --fetch, and stops early when ctx is done. With --cache, repos
+// unchanged since the last run reuse their cached status.
+func gatherStatus(ctx context.Context, repos []config.RepoWithPath) ([]repoState, error) {
+	return gatherStatusEach(ctx, repos, nil)
+}
+
+// gatherStatusEach is like gatherStatus but also hands each repo's state to
+// each (if not nil) once it's known: repos that need no git call first, the
+// rest as their reads finish. The returned states are in config order.
+func gatherStatusEach(ctx context.Context, repos []config.RepoWithPath, each func(repoState)) ([]repoState, error) {
+	if each == nil {
+		each = func(repoState) {}
+	}
+	cache := openStatusCache()
+	fingerprints := make(map[string]string)
+	index := make(map[string]int)
+	states := make([]repoState, 0, len(repos))
+	var targets []git.StatusTarget
 	for _, repo := range repos {
-		displayPath := repo.Path + "." + repo.Name
-		entry := jsonRepo{
-			ID:   displayPath,
-			Path: repo.FullPath,
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		state := repoState{Repo: repo, Cloned: git.Exists(repo.FullPath)}
+		pending := false
+		if state.Cloned {
+			remote := repo.Repo.RemoteName()
+			var cached *git.RepoStatus
+			// A fetch changes the fingerprint, so with --fetch it's taken
+			// after the fetch and nothing cached can be used
+			if !statusFetch {
+				cached, fingerprints[repo.FullPath] = cache.lookup(repo.FullPath, remote)
+			}
+			if cached != nil {
+				state.Status = cached
+			} else {
+				pending = true
+				index[repo.FullPath] = len(states)
+				targets = append(targets, git.StatusTarget{Path: repo.FullPath, Remote: remote})
+			}
+		}
+		states = append(states, state)
+		if !pending {
+			each(state)
 		}
+	}
 
-		if !git.Exists(repo.FullPath) {
-			results = append(results, entry)
-			continue
+	opts := git.StatusOptions{SkipRemote: noRemoteFlag}
+	var mu sync.Mutex
+	if statusFetch {
+		// In the status workers, so fetches run --jobs at a time and each
+		// repo's state is handed on as soon as it's read
+		byPath := make(map[string]config.RepoWithPath, len(repos))
+		for _, repo := range repos {
+			byPath[repo.FullPath] = repo
+		}
+		opts.Fetch = func(ctx context.Context, target git.StatusTarget) error {
+			err := fetchRepo(ctx, byPath[target.Path], false, true)
+			if cache != nil {
+				fingerprint, _ := git.StatusFingerprint(target.Path, target.Remote)
+				mu.Lock()
+				fingerprints[target.Path] = fingerprint
+				mu.Unlock()
+			}
+			return err
 		}
+	}
+	git.StatusEachContext(ctx, targets, statusJobs, timeoutFlag, opts, func(target git.StatusTarget, result git.StatusResult) {
+		if ctx.Err() != nil {
+			return
+		}
+		state := &states[index[target.Path]]
+		state.Status, state.Err, state.FetchErr = result.Status, result.Err, result.FetchErr
+		if result.Err == nil {
+			mu.Lock()
+			fingerprint := fingerprints[target.Path]
+			mu.Unlock()
+			cache.store(target.Path, target.Remote, fingerprint, result.Status)
+		}
+		each(*state)
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	cache.save()
+	return states, nil
+}
 
-		status, err := git.Status(repo.FullPath)
-		if err != nil {
-			results = append(results, entry)
+// bits returns the repo's --exit-code attention bits
+func (s repoState) bits() int {
+	switch {
+	case !s.Cloned:
+		return exitNotCloned
+	case s.Err != nil:
+		return exitErrored
+	}
+	return attention(s.Status)
+}
+
+// upToDate reports whether the repo is fully green: cloned, clean, on a
+// branch level with its tracking branch, with nothing in progress and origin
+// pointing at the configured URL
+func (s repoState) upToDate() bool {
+	if !s.Cloned || s.Err != nil || s.FetchErr != nil {
+		return false
+	}
+	status := s.Status
+	return !status.IsDetached && status.Operation == "" &&
+		attention(status) == 0 && !remoteMismatch(s.Repo, status)
+}
+
+// statusSummary counts repos by state in one line, e.g. "12 repos: 9 clean,
+// 2 dirty, 1 behind, 1 not cloned". Counts overlap (a dirty repo can also be
+// behind) and zero counts are left out. Colors match the table.
+func statusSummary(states []repoState) string {
+	var clean, dirty, bare, ahead, behind, detached, notCloned, errored int
+	for _, state := range states {
+		switch {
+		case !state.Cloned:
+			notCloned++
+			continue
+		case state.Err != nil:
+			errored++
 			continue
 		}
+		status := state.Status
+		if status.IsBare {
+			bare++
+			continue
+		}
+		if status.IsDirty {
+			dirty++
+		} else {
+			clean++
+		}
+		if status.IsDetached {
+			detached++
+			continue
+		}
+		if status.Ahead > 0 {
+			ahead++
+		}
+		if status.Behind > 0 {
+			behind++
+		}
+	}
+
+	total := pluralize(len(states), "repo", "repos")
+	var counts []string
+	for _, c := range []struct {
+		n     int
+		label string
+		color string
+	}{
+		{clean, "clean", colorGreen},
+		{dirty, "dirty", colorYellow},
+		{bare, "bare", colorCyan},
+		{ahead, "ahead", colorYellow},
+		{behind, "behind", colorRed},
+		{detached, "detached", colorCyan},
+		{notCloned, "not cloned", colorGray},
+		{errored, "errored", colorGray},
+	} {
+		if c.n > 0 {
+			counts = append(counts, colorize(c.color, fmt.Sprintf("%d %s", c.n, c.label)))
+		}
+	}
+	if len(counts) == 0 {
+		return total
+	}
+	return total + ": " + strings.Join(counts, ", ")
+}
 
-		entry.Branch = &jsonBranch{
-			Name:     status.Branch,
-			Detached: status.IsDetached,
+// printPlainStatus prints the status table with the view's columns. Rows
+// are gathered first so every column can be sized to its widest cell, and
+// with a width the COMMENTS and then BRANCH columns are cut to fit it.
+func printPlainStatus(states []repoState, view statusView) {
+	rows := plainRows(states, view.fields, view.changed)
+	if view.width > 0 {
+		offset := 0
+		if view.changed != nil {
+			offset = 1 // the gutter
+		}
+		var columns []int
+		for _, name := range []string{"comments", "branch"} {
+			for i, field := range view.fields {
+				if field.name == name {
+					columns = append(columns, offset+i)
+				}
+			}
 		}
+		fitColumns(rows, columns, view.width)
+	}
+	writeTable(os.Stdout, rows)
+}
+
+// minFitWidth is how narrow fitColumns cuts a column at most
+const minFitWidth = 8
 
-		lastCommit := ""
-		if !status.LastCommitTime.IsZero() {
-			lastCommit = status.LastCommitTime.Format(time.RFC3339)
+// fitColumns cuts the cells of columns, in that order and each down to
+// minFitWidth at most, until rows as writeTable writes them fit in width
+// terminal columns
+func fitColumns(rows [][]string, columns []int, width int) {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], visibleWidth(cell))
+		}
+	}
+	total := 2 * max(0, len(widths)-1)
+	for _, w := range widths {
+		total += w
+	}
+	for _, column := range columns {
+		cut := min(total-width, widths[column]-minFitWidth)
+		if cut <= 0 {
+			continue
 		}
-		entry.Changes = &jsonChanges{
-			Dirty:      status.IsDirty,
-			Files:      status.DirtyFiles,
-			LastCommit: lastCommit,
+		for _, row := range rows {
+			if column < len(row) {
+				row[column] = truncateVisible(row[column], widths[column]-cut)
+			}
 		}
+		total -= cut
+	}
+}
+
+// terminalWidth returns the columns of the terminal on stdout, 0 if it
+// isn't one
+func terminalWidth() int {
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return 0
+	}
+	return width
+}
 
-		entry.Remote = &jsonRemote{
-			Ahead:    status.Ahead,
-			Behind:   status.Behind,
-			Diverged: status.Ahead > 0 || status.Behind > 0,
-			Tracking: !status.NoTracking,
+// plainRows builds the status table's rows. With changed (in a watch), each
+// row starts with a gutter marking the repos in it, as wide on every redraw
+// so the table doesn't shift.
+func plainRows(states []repoState, fields []statusField, changed map[string]bool) [][]string {
+	gutter := func(marked bool) []string {
+		if changed == nil {
+			return nil
+		}
+		if marked {
+			return []string{colorize(colorYellow, glyphs.changed)}
 		}
+		return []string{padRight("", visibleWidth(glyphs.changed))}
+	}
+	var rows [][]string
+	if !noHeaders {
+		rows = append(rows, append(gutter(false), headerRow(fields)...))
+	}
+	for _, state := range states {
+		rows = append(rows, append(gutter(changed[state.Repo.Path+"."+state.Repo.Name]), statusRow(state, fields)...))
+	}
+	return rows
+}
 
-		results = append(results, entry)
+// printJSONStatus prints the status array
+func printJSONStatus(states []repoState, unmanaged []unmanagedRepo, skipped []skippedRepo) error {
+	var results []jsonRepo
+	for _, state := range states {
+		results = append(results, jsonEntry(state))
 	}
+	results = append(results, jsonExtraEntries(unmanaged, skipped)...)
 
 	output, err := json.MarshalIndent(results, "", "  ")
 	if err != nil {
@@ -200,118 +908,308 @@ func printJSONStatus(repos []config.RepoWithPath) error {
 	return nil
 }
 
-func printRepoStatus(displayPath, fullPath string, pathWidth, branchWidth, workWidth, remoteWidth, ageWidth int) {
-	// Truncate path if needed
-	pathText := truncate(displayPath, pathWidth)
+// ndjsonStatus prints a line of JSON per repo as its status is read, in the
+// order they finish, then a line per untracked and skipped repo. Each line
+// is the object the JSON array would hold for the repo.
+func ndjsonStatus(ctx context.Context, repos []config.RepoWithPath, unmanaged []unmanagedRepo, skipped []skippedRepo) ([]repoState, error) {
+	encoder := json.NewEncoder(os.Stdout)
+	var writeErr error
+	states, err := gatherStatusEach(ctx, repos, func(state repoState) {
+		if (onlyChanges && state.upToDate()) || writeErr != nil {
+			return
+		}
+		writeErr = encoder.Encode(jsonEntry(state))
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range jsonExtraEntries(unmanaged, skipped) {
+		if writeErr == nil {
+			writeErr = encoder.Encode(entry)
+		}
+	}
+	if writeErr != nil {
+		return nil, fmt.Errorf("failed to write status: %w", writeErr)
+	}
+	return states, nil
+}
+
+// jsonEntry returns the JSON object of a configured repo's state
+func jsonEntry(state repoState) jsonRepo {
+	repo, status := state.Repo, state.Status
+	entry := jsonRepo{
+		ID:       repo.Path + "." + repo.Name,
+		Path:     repo.FullPath,
+		Tags:     repo.Repo.Tags,
+		Disabled: !repo.Repo.IsEnabled(),
+		Mirror:   repo.Repo.Mirror,
+	}
+	if !state.Cloned || state.Err != nil {
+		return entry
+	}
 
-	// Check if repo exists
-	if !git.Exists(fullPath) {
-		path := padRight(pathText, pathWidth)
-		branch := padRight(colorize(colorGray, "—"), branchWidth)
-		work := padRight(colorize(colorGray, "—"), workWidth)
-		remote := padRight(colorize(colorGray, "—"), remoteWidth)
-		age := padRight(colorize(colorGray, "—"), ageWidth)
-		comment := colorize(colorGray, "not cloned")
-		fmt.Printf("%s  %s  %s  %s  %s  %s\n", path, branch, work, remote, age, comment)
-		return
+	entry.Bare = status.IsBare
+	entry.Branch = &jsonBranch{
+		Name:     status.Branch,
+		Detached: status.IsDetached,
+	}
+
+	lastCommit := ""
+	if !status.LastCommitTime.IsZero() {
+		lastCommit = status.LastCommitTime.Format(time.RFC3339)
+	}
+	entry.Changes = &jsonChanges{
+		Dirty:      status.IsDirty,
+		Files:      status.DirtyFiles,
+		LastCommit: lastCommit,
+		Operation:  status.Operation,
+	}
+	if longFlag {
+		entry.Changes.Author = status.Author
+		entry.Changes.Subject = status.Subject
+	}
+
+	entry.Remote = &jsonRemote{
+		Name:        status.Remote,
+		Ahead:       status.Ahead,
+		Behind:      status.Behind,
+		Diverged:    status.Ahead > 0 || status.Behind > 0,
+		Tracking:    !status.NoTracking,
+		URL:         status.RemoteURL,
+		URLMismatch: remoteMismatch(repo, status),
+		Skipped:     status.RemoteSkipped || status.IsBare,
+	}
+	if !status.LastFetch.IsZero() {
+		entry.Remote.LastFetch = status.LastFetch.Format(time.RFC3339)
+	}
+	if state.FetchErr != nil {
+		entry.Remote.FetchError = state.FetchErr.Error()
+	}
+
+	return entry
+}
+
+// jsonExtraEntries returns the JSON objects of untracked and skipped repos,
+// which follow the configured ones
+func jsonExtraEntries(unmanaged []unmanagedRepo, skipped []skippedRepo) []jsonRepo {
+	var results []jsonRepo
+	for _, repo := range unmanaged {
+		results = append(results, jsonRepo{
+			ID:        repo.ID,
+			Path:      repo.FullPath,
+			Unmanaged: true,
+		})
+	}
+
+	for _, repo := range skipped {
+		results = append(results, jsonRepo{
+			ID:      repo.Path + "." + repo.Name,
+			Path:    repo.FullPath,
+			Tags:    repo.Repo.Tags,
+			Skipped: repo.Reason,
+		})
+	}
+
+	return results
+}
+
+// unmanagedRepo is a git repository under the account root that isn't in
+// the config
+type unmanagedRepo struct {
+	ID       string // dotted path relative to the root, like a configured repo's
+	FullPath string
+}
+
+// findUnmanaged scans the account root for repos missing from the config,
+// limited to those matching one of pathFilters and not --exclude. The scan only goes as deep as the
+// deepest configured repo, so it doesn't walk an entire home directory.
+func findUnmanaged(account *config.Account, pathFilters []string) ([]unmanagedRepo, error) {
+	// Repos outside the config carry no tags, so --tag never selects them
+	if len(tagFlags) > 0 {
+		return nil, nil
+	}
+	root := config.ExpandPath(account.Root)
+	known := make(map[string]bool)
+	maxDepth := 1
+	for _, repo := range account.GetAllRepos("") {
+		known[filepath.Clean(repo.FullPath)] = true
+		if rel, err := filepath.Rel(root, repo.FullPath); err == nil {
+			maxDepth = max(maxDepth, strings.Count(rel, string(filepath.Separator))+1)
+		}
 	}
 
-	status, err := git.Status(fullPath)
+	found, err := git.FindRepos(root, maxDepth)
 	if err != nil {
-		path := padRight(pathText, pathWidth)
-		branch := padRight(colorize(colorGray, "?"), branchWidth)
-		work := padRight(colorize(colorGray, "?"), workWidth)
-		remote := padRight(colorize(colorGray, "?"), remoteWidth)
-		age := padRight(colorize(colorGray, "?"), ageWidth)
-		comment := colorize(colorGray, err.Error())
-		fmt.Printf("%s  %s  %s  %s  %s  %s\n", path, branch, work, remote, age, comment)
+		return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+
+	var unmanaged []unmanagedRepo
+	for _, path := range found {
+		if known[path] {
+			continue
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			continue
+		}
+		id := strings.ReplaceAll(rel, string(filepath.Separator), ".")
+		if !matchesAnyFilter(id, pathFilters) || excluded(id) {
+			continue
+		}
+		unmanaged = append(unmanaged, unmanagedRepo{ID: id, FullPath: path})
+	}
+	return unmanaged, nil
+}
+
+// matchesAnyFilter reports whether the dotted id is selected by one of
+// filters, or filters is empty
+func matchesAnyFilter(id string, filters []string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, filter := range filters {
+		if config.MatchesPath(id, filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// printPlainUnmanaged lists unmanaged repos below the status table
+func printPlainUnmanaged(unmanaged []unmanagedRepo) {
+	if len(unmanaged) == 0 {
 		return
 	}
+	fmt.Printf("\nUnmanaged repos (not in config, add them to repos to manage them):\n")
+	var rows [][]string
+	for _, repo := range unmanaged {
+		rows = append(rows, []string{truncate(displayedPath(repo.ID, repo.FullPath), pathWidth), colorize(colorGray, repo.FullPath)})
+	}
+	writeTable(os.Stdout, rows)
+}
 
-	// Format path
-	path := padRight(pathText, pathWidth)
+// printPlainSkipped lists the repos --show-skipped revealed, with the reason
+func printPlainSkipped(skipped []skippedRepo) {
+	if len(skipped) == 0 {
+		return
+	}
+	fmt.Printf("\nSkipped repos (left out on this machine):\n")
+	var rows [][]string
+	for _, repo := range skipped {
+		rows = append(rows, []string{truncate(displayedPath(repo.Path+"."+repo.Name, repo.FullPath), pathWidth), colorize(colorGray, repo.Reason)})
+	}
+	writeTable(os.Stdout, rows)
+}
 
-	// Format branch (truncate with ellipsis if too long)
-	branchText := truncate(status.Branch, branchWidth)
-	var branch string
-	if status.IsDetached {
-		branch = padRight(colorize(colorCyan, branchText), branchWidth)
-	} else {
-		branch = padRight(branchText, branchWidth)
+// writePaths writes a line per repo naming it as --display says
+func writePaths(w io.Writer, repos []config.RepoWithPath) error {
+	var b strings.Builder
+	for _, repo := range repos {
+		b.WriteString(displayedPath(repo.Path+"."+repo.Name, repo.FullPath) + "\n")
 	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
 
-	// Format work status
-	var workText string
-	if status.IsDirty {
-		workText = fmt.Sprintf("● %d", status.DirtyFiles)
-	} else {
-		workText = "✔"
+// changedFlag returns the first of names given on cmd's command line, ""
+// for none. Values from [settings] don't count.
+func changedFlag(cmd *cobra.Command, names ...string) string {
+	for _, name := range names {
+		if cmd.Flags().Changed(name) {
+			return name
+		}
 	}
-	var work string
-	if status.IsDirty {
-		work = padRight(colorize(colorYellow, workText), workWidth)
-	} else {
-		work = padRight(colorize(colorGreen, workText), workWidth)
-	}
-
-	// Format remote status
-	var remoteText string
-	var comments []string
-
-	if status.IsDetached {
-		remoteText = "✔"
-		comments = append(comments, "detached HEAD")
-	} else if status.NoTracking {
-		remoteText = "↑?"
-		comments = append(comments, "no tracking branch")
-	} else if status.Ahead > 0 && status.Behind > 0 {
-		remoteText = fmt.Sprintf("↓%d ↑%d", status.Behind, status.Ahead)
-		comments = append(comments, "diverged")
-	} else if status.Behind > 0 {
-		remoteText = fmt.Sprintf("↓%d", status.Behind)
-		comments = append(comments, fmt.Sprintf("%d commits behind origin", status.Behind))
-	} else if status.Ahead > 0 {
-		remoteText = fmt.Sprintf("↑%d", status.Ahead)
-		comments = append(comments, fmt.Sprintf("%d unpushed commits", status.Ahead))
-	} else {
-		remoteText = "✔"
-	}
-
-	var remote string
-	if status.IsDetached || (!status.NoTracking && status.Ahead == 0 && status.Behind == 0) {
-		remote = padRight(colorize(colorGreen, remoteText), remoteWidth)
-	} else if status.NoTracking || status.Ahead > 0 && status.Behind == 0 {
-		remote = padRight(colorize(colorYellow, remoteText), remoteWidth)
-	} else if status.Behind > 0 && status.Ahead == 0 {
-		remote = padRight(colorize(colorRed, remoteText), remoteWidth)
-	} else {
-		remote = padRight(colorize(colorMagenta, remoteText), remoteWidth)
-	}
-
-	// Add dirty files comment
-	if status.IsDirty {
-		comments = append([]string{fmt.Sprintf("%d dirty files", status.DirtyFiles)}, comments...)
+	return ""
+}
+
+// displayedPath names a repo with dotted id and directory fullPath as
+// --display asks. The relative path follows from the id, since the tree
+// mirrors the directories under the account root.
+func displayedPath(id, fullPath string) string {
+	switch displayFlag {
+	case displayRelpath:
+		return filepath.FromSlash(strings.ReplaceAll(id, ".", "/"))
+	case displayAbspath:
+		return fullPath
+	}
+	return id
+}
+
+// writeTable writes rows as columns separated by two spaces, each as wide as
+// its widest cell. Widths are measured with visibleWidth, so cells may carry
+// color codes. The last column isn't padded.
+func writeTable(w io.Writer, rows [][]string) {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], visibleWidth(cell))
+		}
 	}
 
-	// Format age
-	var age string
-	if status.LastCommitTime.IsZero() {
-		age = padRight(colorize(colorGray, "?"), ageWidth)
-	} else {
-		ageText := formatRelativeTime(status.LastCommitTime)
-		age = padRight(colorize(colorGray, ageText), ageWidth)
+	for _, row := range rows {
+		writeRow(w, row, widths)
 	}
+}
+
+// writeRow writes one table row, padding each cell but the last to its
+// column's width
+func writeRow(w io.Writer, row []string, widths []int) {
+	var line strings.Builder
+	for i, cell := range row {
+		if i > 0 {
+			line.WriteString("  ")
+		}
+		if i == len(row)-1 {
+			line.WriteString(cell)
+		} else {
+			line.WriteString(padRight(cell, widths[i]))
+		}
+	}
+	fmt.Fprintln(w, strings.TrimRight(line.String(), " "))
+}
+
+// remoteMismatch reports whether the clone's origin points somewhere other
+// than the configured URL, e.g. after the URL was changed in the config
+func remoteMismatch(repo config.RepoWithPath, status *git.RepoStatus) bool {
+	return status.RemoteURL != "" && !git.SameURL(status.RemoteURL, repo.Repo.URL)
+}
 
-	comment := colorize(colorGray, strings.Join(comments, ", "))
+// Exit code bits for status --exit-code, OR-ed together so scripts can tell
+// which kinds of problems are present
+const (
+	exitDirty     = 1 << iota // a repo has uncommitted changes
+	exitNotCloned             // a repo hasn't been cloned
+	exitUnsynced              // a repo is ahead, behind, or has no tracking branch
+	exitErrored               // a repo's status couldn't be read
+)
 
-	fmt.Printf("%s  %s  %s  %s  %s  %s\n", path, branch, work, remote, age, comment)
+// attention returns the exit code bits for a cloned repo's status
+func attention(status *git.RepoStatus) int {
+	bits := 0
+	if status.IsDirty {
+		bits |= exitDirty
+	}
+	if !status.IsDetached && !status.IsBare && !status.RemoteSkipped && (status.NoTracking || status.Ahead > 0 || status.Behind > 0) {
+		bits |= exitUnsynced
+	}
+	return bits
 }
 
-// formatRelativeTime formats a time as a relative age string
+// formatRelativeTime formats a time as a relative age string. A zero time
+// is "?", and a time more than a minute ahead (clock skew, rewritten commit
+// dates) is "soon" rather than a negative age.
 func formatRelativeTime(t time.Time) string {
+	if t.IsZero() {
+		return "?"
+	}
 	duration := time.Since(t)
 
 	switch {
+	case duration < -time.Minute:
+		return "soon"
 	case duration < time.Minute:
 		return "now"
 	case duration < time.Hour:
@@ -335,48 +1233,158 @@ func formatRelativeTime(t time.Time) string {
 	}
 }
 
-// truncate shortens a string to maxLen, adding ellipsis if truncated
+// pluralize returns n with the singular or plural word for it, e.g.
+// "1 commit" or "3 commits"
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
+
+// truncate shortens a string to maxLen runes, ending in the glyph set's
+// ellipsis if truncated. A maxLen of 0 or less means no limit.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
+	runes := []rune(s)
+	if maxLen <= 0 || len(runes) <= maxLen {
 		return s
 	}
-	return s[:maxLen-1] + "…"
+	ellipsis := []rune(glyphs.ellipsis)
+	if maxLen <= len(ellipsis) {
+		return string(runes[:maxLen])
+	}
+	return string(runes[:maxLen-len(ellipsis)]) + glyphs.ellipsis
 }
 
-// padRight pads a string to width, accounting for ANSI color codes
-func padRight(s string, width int) string {
-	// Count visible length (excluding ANSI codes)
-	visible := 0
-	inEscape := false
+// truncateVisible is truncate for cells with escape sequences, which are
+// kept and not counted, like visibleWidth does. A colored cell that's cut
+// ends with colorReset, as the cut may drop its own.
+func truncateVisible(s string, maxLen int) string {
+	if maxLen <= 0 || visibleWidth(s) <= maxLen {
+		return s
+	}
+	ellipsis := glyphs.ellipsis
+	keep := maxLen - visibleWidth(ellipsis)
+	if keep <= 0 {
+		keep, ellipsis = maxLen, ""
+	}
+
+	const (
+		stateText = iota
+		stateEscape
+		stateCSI
+	)
+	var b strings.Builder
+	visible, state, styled := 0, stateText, false
 	for _, r := range s {
-		if r == '\033' {
-			inEscape = true
-		} else if inEscape && r == 'm' {
-			inEscape = false
-		} else if !inEscape {
-			visible++
+		switch state {
+		case stateText:
+			if r == '\033' {
+				state, styled = stateEscape, true
+			} else if visible == keep {
+				b.WriteString(ellipsis)
+				if styled {
+					b.WriteString(colorReset)
+				}
+				return b.String()
+			} else {
+				visible++
+			}
+		case stateEscape:
+			if r == '[' {
+				state = stateCSI
+			} else {
+				state = stateText
+			}
+		case stateCSI:
+			if r >= 0x40 && r <= 0x7E {
+				state = stateText
+			}
 		}
+		b.WriteRune(r)
 	}
+	return b.String()
+}
+
+// padRight pads a string to width, accounting for ANSI escape sequences
+func padRight(s string, width int) string {
+	visible := visibleWidth(s)
 	if visible >= width {
 		return s
 	}
 	return s + strings.Repeat(" ", width-visible)
 }
 
+// visibleWidth counts the runes of s that occupy a column, skipping escape
+// sequences. CSI sequences (ESC '[' params final) are skipped in full whatever
+// their final byte, so cursor movement or erase codes don't desync alignment.
+// Other two-byte escapes (ESC followed by a single character) are skipped too.
+func visibleWidth(s string) int {
+	const (
+		stateText = iota
+		stateEscape
+		stateCSI
+	)
+
+	visible := 0
+	state := stateText
+	for _, r := range s {
+		switch state {
+		case stateText:
+			if r == '\033' {
+				state = stateEscape
+			} else {
+				visible++
+			}
+		case stateEscape:
+			if r == '[' {
+				state = stateCSI
+			} else {
+				state = stateText
+			}
+		case stateCSI:
+			// Parameter (0x30-0x3F) and intermediate (0x20-0x2F) bytes
+			// continue the sequence; a final byte (0x40-0x7E) ends it.
+			if r >= 0x40 && r <= 0x7E {
+				state = stateText
+			}
+		}
+	}
+	return visible
+}
+
 func colorize(color, text string) string {
-	if noColor || !isTerminal() {
+	if !colorEnabled(noColor, isTerminal(), os.Getenv) {
 		// Strip any existing ANSI codes and return plain text
 		return stripAnsi(text)
 	}
 	return color + text + colorReset
 }
 
+// colorEnabled decides whether to emit color. --no-color or a non-empty
+// NO_COLOR (https://no-color.org) turn it off; otherwise a CLICOLOR_FORCE
+// other than "" or "0" turns it on even when piped; otherwise color is used
+// only on a terminal.
+func colorEnabled(noColorFlag, terminal bool, getenv func(string) string) bool {
+	if noColorFlag || getenv("NO_COLOR") != "" {
+		return false
+	}
+	if force := getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+		return true
+	}
+	return terminal
+}
+
 func isTerminal() bool {
 	fileInfo, _ := os.Stdout.Stat()
 	return (fileInfo.Mode() & os.ModeCharDevice) != 0
 }
 
+// ansiPattern matches SGR (color/style) escape sequences
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// stripAnsi removes color escape sequences, e.g. ones git embedded in a
+// branch name or error message, so non-terminal output is plain text
 func stripAnsi(s string) string {
-	// Simple approach - if no color, we won't have added any codes
-	return s
+	return ansiPattern.ReplaceAllString(s, "")
 }
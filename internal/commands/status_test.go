@@ -0,0 +1,458 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/oschrenk/arbol/internal/config"
+	"github.com/oschrenk/arbol/internal/git"
+)
+
+func TestStripAnsi(t *testing.T) {
+	cases := map[string]string{
+		"plain":                               "plain",
+		"\x1b[31mred\x1b[0m":                  "red",
+		"feature/\x1b[1;32mlogin\x1b[m":       "feature/login",
+		"\x1b[38;5;208morange\x1b[0m text":    "orange text",
+		"fatal: \x1b[38;2;255;0;0mbad\x1b[0m": "fatal: bad",
+		"":                                    "",
+	}
+	for in, want := range cases {
+		if got := stripAnsi(in); got != want {
+			t.Errorf("stripAnsi(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestColorizeStripsWhenDisabled(t *testing.T) {
+	prev := noColor
+	noColor = true
+	defer func() { noColor = prev }()
+
+	if got := colorize(colorRed, "\x1b[32mmain\x1b[0m"); got != "main" {
+		t.Errorf("colorize with color disabled = %q, want %q", got, "main")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	cases := []struct {
+		in     string
+		maxLen int
+		want   string
+	}{
+		{"main", 10, "main"},
+		{"main", 4, "main"},
+		{"feature/login", 8, "feature…"},
+		// Byte slicing would cut "ü" (2 bytes) in half here.
+		{"feature/über-cool", 10, "feature/ü…"},
+		{"功能/登录页面", 5, "功能/登…"},
+		{"feature/login", 0, "feature/login"}, // no limit
+	}
+	for _, c := range cases {
+		got := truncate(c.in, c.maxLen)
+		if got != c.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", c.in, c.maxLen, got, c.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("truncate(%q, %d) produced invalid UTF-8 %q", c.in, c.maxLen, got)
+		}
+	}
+}
+
+func TestTruncateVisible(t *testing.T) {
+	cases := []struct {
+		in     string
+		maxLen int
+		want   string
+	}{
+		{"feature/login", 8, "feature…"},
+		{"\x1b[33mfeature/login\x1b[0m", 8, "\x1b[33mfeature…\x1b[0m"},
+		{"\x1b[33mmain\x1b[0m", 4, "\x1b[33mmain\x1b[0m"},
+		{"\x1b[2mwip\x1b[0m and more", 6, "\x1b[2mwip\x1b[0m a…\x1b[0m"},
+		{"feature/login", 0, "feature/login"}, // no limit
+	}
+	for _, c := range cases {
+		if got := truncateVisible(c.in, c.maxLen); got != c.want {
+			t.Errorf("truncateVisible(%q, %d) = %q, want %q", c.in, c.maxLen, got, c.want)
+		}
+	}
+}
+
+func TestFitColumns(t *testing.T) {
+	rows := func() [][]string {
+		return [][]string{
+			{"REPO", "BRANCH", "COMMENTS"},
+			{"api", "feature/a-long-branch", "ship it after the review, see the thread"},
+		}
+	}
+
+	// Wide enough: nothing is cut.
+	got := rows()
+	fitColumns(got, []int{2, 1}, 80)
+	if got[1][1] != "feature/a-long-branch" || got[1][2] != "ship it after the review, see the thread" {
+		t.Errorf("fitColumns(80) cut cells: %q", got[1])
+	}
+
+	// Comments go first, down to minFitWidth, then the branch.
+	got = rows()
+	fitColumns(got, []int{2, 1}, 30)
+	if w := visibleWidth(got[1][2]); w != minFitWidth {
+		t.Errorf("comments width = %d, want %d (%q)", w, minFitWidth, got[1][2])
+	}
+	if w := visibleWidth(got[1][1]); w != 30-len("REPO")-minFitWidth-2*2 {
+		t.Errorf("branch width = %d, want %d (%q)", w, 30-len("REPO")-minFitWidth-2*2, got[1][1])
+	}
+	if got[0][1] != "BRANCH" || got[1][0] != "api" {
+		t.Errorf("fitColumns cut short cells or other columns: %q", got)
+	}
+
+	// Too narrow even then: columns stop at minFitWidth.
+	got = rows()
+	fitColumns(got, []int{2, 1}, 10)
+	if w := visibleWidth(got[1][1]); w != minFitWidth {
+		t.Errorf("branch width = %d, want %d (%q)", w, minFitWidth, got[1][1])
+	}
+}
+
+func TestFormatRelativeTime(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name string
+		t    time.Time
+		want string
+	}{
+		{"zero", time.Time{}, "?"},
+		{"just now", now.Add(-10 * time.Second), "now"},
+		{"slight skew", now.Add(30 * time.Second), "now"},
+		{"future", now.Add(3 * time.Hour), "soon"},
+		{"far future", now.AddDate(2, 0, 0), "soon"},
+		{"minutes", now.Add(-5*time.Minute - time.Second), "5m"},
+		{"days", now.Add(-50 * time.Hour), "2d"},
+		{"years", now.AddDate(-3, 0, -1), "3y"},
+	}
+	for _, c := range cases {
+		if got := formatRelativeTime(c.t); got != c.want {
+			t.Errorf("%s: formatRelativeTime() = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestVisibleWidth(t *testing.T) {
+	cases := map[string]int{
+		"main":                            4,
+		"\x1b[32m✔\x1b[0m":                1,
+		"\x1b[38;5;208m↓2 ↑1\x1b[0m":      5,
+		"\x1b[38;2;10;20;30mtrue\x1b[0m":  4,
+		"\x1b[2Kcleared":                  7,
+		"up\x1b[1Aone":                    5,
+		"\x1b[?25lhidden cursor\x1b[?25h": 13,
+		"\x1b7saved\x1b8":                 5,
+		"":                                0,
+	}
+	for in, want := range cases {
+		if got := visibleWidth(in); got != want {
+			t.Errorf("visibleWidth(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestPadRightAlignsMixedContent(t *testing.T) {
+	cells := []string{
+		"main",
+		"\x1b[36mab5c2d0\x1b[0m",
+		"\x1b[38;5;208m● 3\x1b[0m",
+		"\x1b[2K\x1b[33m↑?\x1b[0m",
+	}
+	for _, cell := range cells {
+		padded := padRight(cell, 10)
+		if got := visibleWidth(padded); got != 10 {
+			t.Errorf("padRight(%q, 10) has visible width %d, want 10", cell, got)
+		}
+	}
+
+	// Cells wider than the column are left untouched.
+	if got := padRight("\x1b[31mfeature/login\x1b[0m", 5); got != "\x1b[31mfeature/login\x1b[0m" {
+		t.Errorf("padRight should not modify overlong cell, got %q", got)
+	}
+}
+
+func TestAttention(t *testing.T) {
+	cases := []struct {
+		name   string
+		status git.RepoStatus
+		want   int
+	}{
+		{"clean and in sync", git.RepoStatus{}, 0},
+		{"dirty", git.RepoStatus{IsDirty: true, DirtyFiles: 2}, exitDirty},
+		{"ahead", git.RepoStatus{Ahead: 1}, exitUnsynced},
+		{"behind", git.RepoStatus{Behind: 3}, exitUnsynced},
+		{"no tracking", git.RepoStatus{NoTracking: true}, exitUnsynced},
+		{"detached is not unsynced", git.RepoStatus{IsDetached: true}, 0},
+		{"bare is not unsynced", git.RepoStatus{IsBare: true, NoTracking: true}, 0},
+		{"dirty and diverged", git.RepoStatus{IsDirty: true, Ahead: 1, Behind: 1}, exitDirty | exitUnsynced},
+	}
+	for _, c := range cases {
+		if got := attention(&c.status); got != c.want {
+			t.Errorf("%s: attention() = %d, want %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestRepoStateUpToDate(t *testing.T) {
+	repo := config.RepoWithPath{Repo: config.Repo{URL: "git@github.com:user/repo.git"}}
+	clean := func(mod func(*git.RepoStatus)) repoState {
+		status := &git.RepoStatus{Branch: "main", Remote: "origin", RemoteURL: "https://github.com/user/repo"}
+		if mod != nil {
+			mod(status)
+		}
+		return repoState{Repo: repo, Cloned: true, Status: status}
+	}
+
+	cases := []struct {
+		name  string
+		state repoState
+		want  bool
+	}{
+		{"clean and in sync", clean(nil), true},
+		{"not cloned", repoState{Repo: repo}, false},
+		{"errored", repoState{Repo: repo, Cloned: true, Err: errors.New("boom")}, false},
+		{"dirty", clean(func(s *git.RepoStatus) { s.IsDirty = true }), false},
+		{"detached", clean(func(s *git.RepoStatus) { s.IsDetached = true }), false},
+		{"behind", clean(func(s *git.RepoStatus) { s.Behind = 1 }), false},
+		{"no tracking", clean(func(s *git.RepoStatus) { s.NoTracking = true }), false},
+		{"rebasing", clean(func(s *git.RepoStatus) { s.Operation = git.OperationRebase }), false},
+		{"remote mismatch", clean(func(s *git.RepoStatus) { s.RemoteURL = "git@github.com:fork/repo.git" }), false},
+	}
+	for _, c := range cases {
+		if got := c.state.upToDate(); got != c.want {
+			t.Errorf("%s: upToDate() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestJSONEntry(t *testing.T) {
+	repo := config.RepoWithPath{Repo: config.Repo{URL: "git@github.com:user/repo.git"}, Path: "work", Name: "repo", FullPath: "/projects/work/repo"}
+	status := &git.RepoStatus{Branch: "main", Remote: "origin", Behind: 2, DirtyFiles: 1, IsDirty: true}
+
+	// An NDJSON line is the object the array holds, on one line
+	line, err := json.Marshal(jsonEntry(repoState{Repo: repo, Cloned: true, Status: status}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]any
+	if err := json.Unmarshal(line, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["id"] != "work.repo" || entry["remote"].(map[string]any)["behind"] != 2.0 || entry["changes"].(map[string]any)["files"] != 1.0 {
+		t.Errorf("unexpected entry %s", line)
+	}
+	if bytes.ContainsRune(line, '\n') {
+		t.Errorf("entry spans lines: %s", line)
+	}
+
+	notCloned := jsonEntry(repoState{Repo: repo})
+	if notCloned.Branch != nil || notCloned.Changes != nil || notCloned.Remote != nil {
+		t.Errorf("not cloned repo has status fields: %+v", notCloned)
+	}
+
+	extra := jsonExtraEntries([]unmanagedRepo{{ID: "work.stray", FullPath: "/projects/work/stray"}}, []skippedRepo{{RepoWithPath: repo, Reason: "os"}})
+	if len(extra) != 2 || !extra[0].Unmanaged || extra[1].Skipped != "os" {
+		t.Errorf("unexpected extra entries %+v", extra)
+	}
+}
+
+func TestWritePaths(t *testing.T) {
+	prev := displayFlag
+	defer func() { displayFlag = prev }()
+
+	repos := []config.RepoWithPath{
+		{Path: "work.backend", Name: "api", FullPath: "/projects/work/backend/api"},
+		{Path: "personal", Name: "dotfiles", FullPath: "/projects/personal/dotfiles"},
+	}
+	want := map[string]string{
+		displayDotted:  "work.backend.api\npersonal.dotfiles\n",
+		displayAbspath: "/projects/work/backend/api\n/projects/personal/dotfiles\n",
+	}
+	for display, paths := range want {
+		displayFlag = display
+		var buf bytes.Buffer
+		if err := writePaths(&buf, repos); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != paths {
+			t.Errorf("--display %s: writePaths() = %q, want %q", display, got, paths)
+		}
+	}
+}
+
+func TestChangedRepos(t *testing.T) {
+	status := func(branch string, dirty, behind int) *git.RepoStatus {
+		return &git.RepoStatus{Branch: branch, IsDirty: dirty > 0, DirtyFiles: dirty, Behind: behind, LastFetch: time.Now()}
+	}
+	repo := func(name string) config.RepoWithPath { return config.RepoWithPath{Path: "work", Name: name} }
+	before := watchSnapshots([]repoState{
+		{Repo: repo("api"), Cloned: true, Status: status("main", 0, 0)},
+		{Repo: repo("web"), Cloned: true, Status: status("main", 0, 0)},
+		{Repo: repo("cli"), Cloned: true, Status: status("main", 1, 2)},
+		{Repo: repo("lib")},
+	})
+	// Every status has a new LastFetch, which alone isn't a change
+	after := watchSnapshots([]repoState{
+		{Repo: repo("api"), Cloned: true, Status: status("feature", 0, 0)},
+		{Repo: repo("web"), Cloned: true, Status: status("main", 3, 0)},
+		{Repo: repo("cli"), Cloned: true, Status: status("main", 1, 2)},
+		{Repo: repo("lib"), Cloned: true, Status: status("main", 0, 0)},
+	})
+
+	want := map[string]bool{"work.api": true, "work.web": true, "work.lib": true}
+	if got := changedRepos(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("changedRepos() = %v, want %v", got, want)
+	}
+	if got := changedRepos(nil, after); got == nil || len(got) != 0 {
+		t.Errorf("changedRepos() on the first redraw = %v, want empty", got)
+	}
+}
+
+func TestPlainRowsGutter(t *testing.T) {
+	prevGlyphs, prevColor := glyphs, noColor
+	glyphs, noColor = asciiGlyphs, true
+	defer func() { glyphs, noColor = prevGlyphs, prevColor }()
+
+	fields, err := parseFields("path,branch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := []repoState{
+		{Repo: config.RepoWithPath{Path: "work", Name: "api"}, Cloned: true, Status: &git.RepoStatus{Branch: "main"}},
+		{Repo: config.RepoWithPath{Path: "work", Name: "web"}, Cloned: true, Status: &git.RepoStatus{Branch: "dev"}},
+	}
+
+	want := [][]string{{" ", "PATH", "BRANCH"}, {">", "work.api", "main"}, {" ", "work.web", "dev"}}
+	if got := plainRows(states, fields, map[string]bool{"work.api": true}); !reflect.DeepEqual(got, want) {
+		t.Errorf("plainRows() in a watch = %q, want %q", got, want)
+	}
+	want = [][]string{{"PATH", "BRANCH"}, {"work.api", "main"}, {"work.web", "dev"}}
+	if got := plainRows(states, fields, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("plainRows() = %q, want %q", got, want)
+	}
+}
+
+func TestStatusSummary(t *testing.T) {
+	prev := noColor
+	noColor = true
+	defer func() { noColor = prev }()
+
+	states := []repoState{
+		{Cloned: true, Status: &git.RepoStatus{}},
+		{Cloned: true, Status: &git.RepoStatus{}},
+		{Cloned: true, Status: &git.RepoStatus{IsDirty: true, Behind: 2}},
+		{Cloned: true, Status: &git.RepoStatus{Ahead: 1}},
+		{Cloned: true, Status: &git.RepoStatus{IsDetached: true}},
+		{},
+		{Cloned: true, Err: errors.New("boom")},
+		{Cloned: true, Status: &git.RepoStatus{IsBare: true}},
+	}
+	want := "8 repos: 4 clean, 1 dirty, 1 bare, 1 ahead, 1 behind, 1 detached, 1 not cloned, 1 errored"
+	if got := statusSummary(states); got != want {
+		t.Errorf("statusSummary() = %q, want %q", got, want)
+	}
+
+	if got := statusSummary(states[:1]); got != "1 repo: 1 clean" {
+		t.Errorf("statusSummary(one) = %q, want %q", got, "1 repo: 1 clean")
+	}
+}
+
+func TestCommentsPluralize(t *testing.T) {
+	prev := noColor
+	noColor = true
+	defer func() { noColor = prev }()
+
+	cases := []struct {
+		status *git.RepoStatus
+		want   string
+	}{
+		{&git.RepoStatus{Remote: "origin", IsDirty: true, DirtyFiles: 1}, "1 dirty file"},
+		{&git.RepoStatus{Remote: "origin", IsDirty: true, DirtyFiles: 3}, "3 dirty files"},
+		{&git.RepoStatus{Remote: "origin", Behind: 1}, "1 commit behind origin"},
+		{&git.RepoStatus{Remote: "origin", Ahead: 1}, "1 unpushed commit"},
+		{&git.RepoStatus{Remote: "upstream", Ahead: 2}, "2 commits ahead of upstream"},
+	}
+	for _, c := range cases {
+		state := repoState{Repo: config.RepoWithPath{Repo: config.Repo{Remote: c.status.Remote}}, Cloned: true, Status: c.status}
+		if got := commentsCell(state); got != c.want {
+			t.Errorf("commentsCell(%+v) = %q, want %q", *c.status, got, c.want)
+		}
+	}
+}
+
+func TestColorEnabled(t *testing.T) {
+	cases := []struct {
+		name     string
+		flag     bool
+		terminal bool
+		env      map[string]string
+		want     bool
+	}{
+		{"terminal", false, true, nil, true},
+		{"piped", false, false, nil, false},
+		{"flag", true, true, nil, false},
+		{"NO_COLOR", false, true, map[string]string{"NO_COLOR": "1"}, false},
+		{"empty NO_COLOR is ignored", false, true, map[string]string{"NO_COLOR": ""}, true},
+		{"CLICOLOR_FORCE when piped", false, false, map[string]string{"CLICOLOR_FORCE": "1"}, true},
+		{"CLICOLOR_FORCE=0", false, false, map[string]string{"CLICOLOR_FORCE": "0"}, false},
+		{"NO_COLOR beats CLICOLOR_FORCE", false, false, map[string]string{"NO_COLOR": "1", "CLICOLOR_FORCE": "1"}, false},
+		{"flag beats CLICOLOR_FORCE", true, false, map[string]string{"CLICOLOR_FORCE": "1"}, false},
+	}
+	for _, c := range cases {
+		getenv := func(key string) string { return c.env[key] }
+		if got := colorEnabled(c.flag, c.terminal, getenv); got != c.want {
+			t.Errorf("%s: colorEnabled() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	rows := [][]string{
+		{"PATH", "BRANCH", "COMMENTS"},
+		{"work.backend.api", "\x1b[36mab5c2d0\x1b[0m", "detached HEAD"},
+		{"personal.dotfiles", "feature/über-long-branch", ""},
+	}
+	var buf bytes.Buffer
+	writeTable(&buf, rows)
+
+	want := "PATH               BRANCH                    COMMENTS\n" +
+		"work.backend.api   \x1b[36mab5c2d0\x1b[0m                   detached HEAD\n" +
+		"personal.dotfiles  feature/über-long-branch\n"
+	if got := buf.String(); got != want {
+		t.Errorf("writeTable() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestStreamWidths(t *testing.T) {
+	fields, err := parseFields("path,branch,work,ahead,comments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repos := []config.RepoWithPath{
+		{Path: "work.backend", Name: "api", FullPath: "/p/work/backend/api"},
+		{Path: "personal", Name: "dotfiles-and-more", FullPath: "/p/personal/dotfiles-and-more"},
+	}
+
+	branchWidth = 0
+	want := []int{len("personal.dotfiles-and-more"), defaultStreamBranchWidth, len("mirror"), len("AHEAD"), len("COMMENTS")}
+	if got := streamWidths(repos, fields); !reflect.DeepEqual(got, want) {
+		t.Errorf("streamWidths() = %v, want %v", got, want)
+	}
+
+	pathWidth, branchWidth = 10, 8
+	defer func() { pathWidth, branchWidth = 0, 0 }()
+	want[0], want[1] = 10, 8
+	if got := streamWidths(repos, fields); !reflect.DeepEqual(got, want) {
+		t.Errorf("streamWidths() with widths set = %v, want %v", got, want)
+	}
+}
@@ -1,101 +1,331 @@
 package commands
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/oschrenk/arbol/internal/config"
 	"github.com/oschrenk/arbol/internal/git"
 	"github.com/spf13/cobra"
 )
 
-var fetchFlag bool
+var (
+	fetchFlag        bool
+	dryRunFlag       bool
+	updateRemoteFlag bool
+	confirmOverFlag  int
+)
 
 var syncCmd = &cobra.Command{
-	Use:   "sync [path]",
+	Use:   "sync [path...]",
 	Short: "Clone missing repositories",
 	Long: `Clone missing repositories from the configuration.
 
 Without a path argument, syncs all repos in the account.
-With a path, syncs only repos under that path.
+With paths, syncs only repos under any of them.
+
+Paths may be globs matched against the dotted path ('work.*'); quote them
+so the shell doesn't expand them. Use --exclude (repeatable) to skip repos
+matching a path or glob.
 
 Use --fetch to also fetch updates for existing repositories.
+Use --update-remote to point origin of existing repos back at the URL in the
+config when they differ.
+Use --dry-run to print what would be done without cloning or fetching.
+Use --retries to set how often a clone or fetch that failed with a network
+error is retried, waiting 1s, 2s, 4s, ... in between (default 2).
+Repos with post_clone commands get them run in the fresh clone, and the
+post_sync commands of [settings] run once at the end, unless --skip-hooks is
+given.
+When more than --confirm-over repos (default 20) would be cloned and stdin
+is a terminal, sync asks before starting; --yes skips the question.
+On a terminal, sync shows a single progress line ("[12/48] clone
+work.backend.api") instead of a line per repo, with errors printed above
+it; --progress=false turns it off and --progress forces it on.
+Use --quiet (-q) to print only errors and the summary, for scripts; -qq
+prints nothing unless something failed.
+Use --log to append one JSON line per clone, fetch, and post_clone hook
+(time, account, repo, action, result, duration) to the history log, by
+default history.log next to the config file.
+Use --partial to clone without file contents (git clone --filter=blob:none),
+which git then downloads as checkouts need them; partial = true does the
+same for a single repo. It makes the first clone of a repo with a long
+history much smaller, but needs git and a server that supports it.
+Use --ssh-key to clone with a specific private key, e.g. a deploy key; an
+encrypted key's passphrase is read from $ARBOL_SSH_PASSPHRASE or prompted for.
 
 Examples:
   arbol sync                    # sync all repos
   arbol sync work.backend       # sync repos under work.backend
   arbol sync personal.dotfiles  # sync single repo
-  arbol sync --fetch            # sync all and fetch existing`,
-	Args: cobra.MaximumNArgs(1),
+  arbol sync work personal      # sync repos under work and personal
+  arbol sync 'work.*'           # glob, quoted so the shell doesn't expand it
+  arbol sync --exclude personal.archive
+  arbol sync --fetch            # sync all and fetch existing
+  arbol sync --dry-run          # preview without touching the filesystem`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		account, accountName, err := getAccount()
 		if err != nil {
 			return err
 		}
 
-		pathFilter := ""
-		if len(args) > 0 {
-			pathFilter = args[0]
+		repos, filters, err := selectRepos(account, accountName, args)
+		if err != nil {
+			return err
 		}
-
-		repos := account.GetRepos(pathFilter)
 		if len(repos) == 0 {
-			if pathFilter != "" {
-				fmt.Printf("No repos found matching '%s' in account '%s'\n", pathFilter, accountName)
+			if selection := describeSelection(filters); selection != "" {
+				fmt.Printf("No repos found %s in account '%s'\n", selection, accountName)
 			} else {
 				fmt.Printf("No repos configured in account '%s'\n", accountName)
 			}
 			return nil
 		}
 
-		var cloned, fetched, skipped, failed int
-
+		toClone := 0
 		for _, repo := range repos {
+			if !git.Exists(repo.FullPath) {
+				toClone++
+			}
+		}
+		root, rootExists, err := checkRoot(account, accountName)
+		if err != nil {
+			return err
+		}
+		if !rootExists && toClone > 0 {
+			// A mistyped root would otherwise quietly grow a second tree
+			fmt.Fprintf(os.Stderr, "warning: root %s of account '%s' does not exist and will be created\n", root, accountName)
+			if !dryRunFlag && !yesFlag && stdinIsTerminal() {
+				if !confirm(stdin, fmt.Sprintf("Create %s?", root)) {
+					return fmt.Errorf("aborted, nothing was cloned")
+				}
+			}
+		}
+		printStep("Plan: %s\n\n", syncPlan(toClone, len(repos)-toClone, fetchFlag))
+		if !dryRunFlag && !yesFlag && confirmOverFlag > 0 && toClone > confirmOverFlag && stdinIsTerminal() {
+			if !confirm(stdin, fmt.Sprintf("About to clone %d repos, continue?", toClone)) {
+				return fmt.Errorf("aborted, nothing was cloned")
+			}
+		}
+
+		if !rootExists && toClone > 0 && !dryRunFlag {
+			if err := os.MkdirAll(root, 0755); err != nil {
+				return fmt.Errorf("failed to create root of account '%s': %w", accountName, err)
+			}
+		}
+
+		var history *historyLog
+		if !dryRunFlag {
+			if history, err = openHistory("sync", accountName); err != nil {
+				return err
+			}
+			defer history.Close()
+		}
+
+		ctx := cmd.Context()
+		var cloned, fetched, skipped, updated, failed int
+		// failed repos that used up all --retries
+		var failedRetried int
+		// cloned repos whose post_clone hook failed
+		var hookFailed int
+
+		if useProgress(cmd.Flags().Changed("progress")) {
+			bar = newProgressBar(len(repos))
+			defer func() { bar = nil }()
+		}
+
+		for i, repo := range repos {
+			if ctx.Err() != nil {
+				break
+			}
 			displayPath := repo.Path + "." + repo.Name
 
-			if git.Exists(repo.FullPath) {
+			exists := git.Exists(repo.FullPath)
+			if bar != nil {
+				bar.done, bar.failed = i, failed+hookFailed
+				switch {
+				case !exists:
+					bar.show("clone", displayPath)
+				case fetchFlag:
+					bar.show("fetch", displayPath)
+				default:
+					bar.show("check", displayPath)
+				}
+			}
+
+			if exists {
+				if updateRemoteFlag {
+					changed, err := updateRemote(repo, displayPath)
+					if err != nil {
+						printProblem("  error %s: %v\n", displayPath, err)
+						failed++
+						continue
+					}
+					if changed {
+						updated++
+					}
+				}
 				if fetchFlag {
-					fmt.Printf("  fetch %s\n", displayPath)
-					if err := git.Fetch(repo.FullPath); err != nil {
-						fmt.Printf("  error %s: %v\n", displayPath, err)
+					printStep("  fetch %s\n", displayPath)
+					if dryRunFlag {
+						fetched++
+						continue
+					}
+					start := time.Now()
+					retries, err := withRetries(ctx, displayPath, func(ctx context.Context) error {
+						return fetchRepo(ctx, repo, false, quietFlag > 0 || bar != nil)
+					})
+					if ctx.Err() != nil {
+						history.record(displayPath, "fetch", start, retries, ctx.Err())
+						printProblem("  abort %s (interrupted)\n", displayPath)
+						break
+					}
+					history.record(displayPath, "fetch", start, retries, err)
+					if err != nil {
+						printProblem("  error %s: %v%s\n", displayPath, err, retriesSuffix(retries))
 						failed++
+						if retries > 0 && retries == retriesFlag {
+							failedRetried++
+						}
 						continue
 					}
 					fetched++
 				} else {
-					fmt.Printf("  skip  %s (already exists)\n", displayPath)
+					printStep("  skip  %s (already exists)\n", displayPath)
 					skipped++
 				}
 				continue
 			}
 
-			fmt.Printf("  clone %s\n", displayPath)
-			if err := git.Clone(repo.Repo.URL, repo.FullPath); err != nil {
-				fmt.Printf("  error %s: %v\n", displayPath, err)
+			printStep("  clone %s\n", displayPath)
+			if dryRunFlag {
+				cloned++
+				continue
+			}
+			// Resolved at the first clone, so a sync with nothing to clone
+			// never prompts for a passphrase
+			opts, err := cloneOptions(account, repo.Repo)
+			if err != nil {
+				return err
+			}
+			start := time.Now()
+			retries, err := withRetries(ctx, displayPath, func(ctx context.Context) error {
+				return git.CloneWithOptions(ctx, repo.Repo.URL, repo.FullPath, opts)
+			})
+			if ctx.Err() != nil {
+				history.record(displayPath, "clone", start, retries, ctx.Err())
+				// CloneWithOptions removed the partial directory, so the next
+				// sync retries instead of skipping it as "already exists"
+				printProblem("  abort %s (interrupted, partial clone removed)\n", displayPath)
+				break
+			}
+			history.record(displayPath, "clone", start, retries, err)
+			if err != nil {
+				printProblem("  error %s: %v%s\n", displayPath, err, retriesSuffix(retries))
 				failed++
+				if retries > 0 && retries == retriesFlag {
+					failedRetried++
+				}
 				continue
 			}
 			cloned++
+
+			if len(repo.Repo.PostClone) > 0 && !skipHooksFlag {
+				printStep("  hook  %s\n", displayPath)
+				start := time.Now()
+				err := runQuietHooks(ctx, repo.FullPath, repo.Repo.PostClone, nil)
+				if err != nil && ctx.Err() != nil {
+					err = ctx.Err()
+				}
+				history.record(displayPath, "post_clone", start, 0, err)
+				if err != nil {
+					if ctx.Err() != nil {
+						printProblem("  abort %s (interrupted, clone kept)\n", displayPath)
+						break
+					}
+					// The clone is kept, so the next sync skips this repo;
+					// fix the hook and run it by hand
+					printProblem("  error %s: %v (clone succeeded)\n", displayPath, err)
+					hookFailed++
+				}
+			}
+		}
+
+		if bar != nil {
+			bar.clear()
+			bar = nil
 		}
 
 		// Build summary based on what was done
 		var summary []string
-		if cloned > 0 {
-			summary = append(summary, fmt.Sprintf("%d cloned", cloned))
-		}
-		if fetched > 0 {
-			summary = append(summary, fmt.Sprintf("%d fetched", fetched))
+		if dryRunFlag {
+			if cloned > 0 {
+				summary = append(summary, fmt.Sprintf("would clone %d", cloned))
+			}
+			if fetched > 0 {
+				summary = append(summary, fmt.Sprintf("would fetch %d", fetched))
+			}
+			if updated > 0 {
+				summary = append(summary, "would update "+pluralize(updated, "remote", "remotes"))
+			}
+		} else {
+			if cloned > 0 {
+				summary = append(summary, fmt.Sprintf("%d cloned", cloned))
+			}
+			if fetched > 0 {
+				summary = append(summary, fmt.Sprintf("%d fetched", fetched))
+			}
+			if updated > 0 {
+				summary = append(summary, pluralize(updated, "remote", "remotes")+" updated")
+			}
 		}
 		if skipped > 0 {
 			summary = append(summary, fmt.Sprintf("%d skipped", skipped))
 		}
-		if failed > 0 {
-			summary = append(summary, fmt.Sprintf("%d failed", failed))
+		if failed > failedRetried {
+			summary = append(summary, fmt.Sprintf("%d failed", failed-failedRetried))
+		}
+		if failedRetried > 0 {
+			summary = append(summary, fmt.Sprintf("%d failed after %s", failedRetried, pluralize(retriesFlag, "retry", "retries")))
+		}
+		if hookFailed > 0 {
+			summary = append(summary, fmt.Sprintf("%d post_clone failed", hookFailed))
 		}
 		if len(summary) == 0 {
 			summary = append(summary, "nothing to do")
 		}
-		fmt.Printf("\nSummary: %s\n", strings.Join(summary, ", "))
+		if ctx.Err() != nil {
+			fmt.Printf("\nInterrupted, %d cloned so far\n", cloned)
+			fmt.Printf("Summary: %s\n", strings.Join(summary, ", "))
+			return errInterrupted
+		}
+		printSummary(strings.Join(summary, ", "), failed+hookFailed > 0)
+
+		if len(cfg.Settings.PostSync) > 0 && !dryRunFlag && !skipHooksFlag {
+			env := []string{
+				"ARBOL_ACCOUNT=" + accountName,
+				"ARBOL_ROOT=" + config.ExpandPath(account.Root),
+				fmt.Sprintf("ARBOL_CLONED=%d", cloned),
+				fmt.Sprintf("ARBOL_FETCHED=%d", fetched),
+				fmt.Sprintf("ARBOL_UPDATED=%d", updated),
+				fmt.Sprintf("ARBOL_SKIPPED=%d", skipped),
+				fmt.Sprintf("ARBOL_FAILED=%d", failed+hookFailed),
+			}
+			printStep("  hook  post_sync\n")
+			if err := runQuietHooks(ctx, "", cfg.Settings.PostSync, env); err != nil {
+				if ctx.Err() != nil {
+					return errInterrupted
+				}
+				return fmt.Errorf("post_sync %w", err)
+			}
+		}
+
+		if failed+hookFailed > 0 {
+			return fmt.Errorf("%s failed to sync", pluralize(failed+hookFailed, "repo", "repos"))
+		}
 		return nil
 	},
 	ValidArgsFunction: completeRepoPath,
@@ -103,32 +333,86 @@ Examples:
 
 func init() {
 	syncCmd.Flags().BoolVar(&fetchFlag, "fetch", false, "Fetch updates for existing repos")
+	syncCmd.Flags().BoolVar(&updateRemoteFlag, "update-remote", false, "Set origin to the configured URL where it differs")
+	syncCmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "Show what would be cloned or fetched without doing it")
+	syncCmd.Flags().IntVar(&retriesFlag, "retries", 2, "Retry clones and fetches that fail with a network error up to N times")
+	syncCmd.Flags().BoolVar(&skipHooksFlag, "skip-hooks", false, "Don't run post_clone and post_sync hooks")
+	syncCmd.Flags().IntVar(&confirmOverFlag, "confirm-over", 20, "Ask before cloning more than N repos, 0 never asks (only on a terminal)")
+	syncCmd.Flags().BoolVarP(&yesFlag, "yes", "y", false, "Don't ask before cloning many repos")
+	syncCmd.Flags().BoolVar(&progressFlag, "progress", false, "Show one updating progress line instead of a line per repo (default on a terminal)")
+	syncCmd.Flags().CountVarP(&quietFlag, "quiet", "q", "Only print errors and the summary; -qq also drops the summary on success")
+	syncCmd.Flags().BoolVar(&logFlag, "log", false, "Append what was done to the history log")
+	syncCmd.Flags().BoolVar(&partialFlag, "partial", false, "Clone without file contents, which git fetches when needed (git clone --filter=blob:none)")
+	syncCmd.Flags().StringVar(&sshKeyFlag, "ssh-key", "", "Private key to clone with instead of the SSH agent (overrides ssh_key)")
+	syncCmd.Flags().StringArrayVar(&excludeFlags, "exclude", nil, "Skip repos matching this path or glob (repeatable)")
+	syncCmd.Flags().StringArrayVar(&tagFlags, "tag", nil, "Only repos with this tag (repeatable, all must match)")
+	syncCmd.Flags().BoolVar(&tagAnyFlag, "tag-any", false, "With several --tag, select repos with any of them")
+	syncCmd.Flags().BoolVar(&includeDisabledFlag, "include-disabled", false, "Also select repos with enabled = false")
 	rootCmd.AddCommand(syncCmd)
 }
 
-// completeRepoPath provides completion for repo paths
+// syncPlan describes the work ahead, e.g. "3 to clone, 5 to fetch", given
+// how many selected repos are missing and present. Present repos are fetched
+// with --fetch and skipped otherwise; zero counts are left out.
+func syncPlan(toClone, present int, fetch bool) string {
+	var plan []string
+	if toClone > 0 {
+		plan = append(plan, fmt.Sprintf("%d to clone", toClone))
+	}
+	if present > 0 {
+		if fetch {
+			plan = append(plan, fmt.Sprintf("%d to fetch", present))
+		} else {
+			plan = append(plan, fmt.Sprintf("%d already present", present))
+		}
+	}
+	return strings.Join(plan, ", ")
+}
+
+// updateRemote points origin of an existing clone at the configured URL if
+// it currently points elsewhere, reporting whether it changed (or would, in
+// dry-run mode)
+func updateRemote(repo config.RepoWithPath, displayPath string) (bool, error) {
+	current, err := git.RemoteURL(repo.FullPath, git.CloneRemote)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s URL: %w", git.CloneRemote, err)
+	}
+	if git.SameURL(current, repo.Repo.URL) {
+		return false, nil
+	}
+
+	printStep("  remote %s (%s -> %s)\n", displayPath, current, repo.Repo.URL)
+	if dryRunFlag {
+		return true, nil
+	}
+	if err := git.SetRemoteURL(repo.FullPath, git.CloneRemote, repo.Repo.URL); err != nil {
+		return false, fmt.Errorf("failed to update %s URL: %w", git.CloneRemote, err)
+	}
+	return true, nil
+}
+
+// completeRepoPath provides completion for repo paths, one level of the
+// tree at a time
 func completeRepoPath(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	if len(args) > 0 {
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
 
-	cfg, err := config.Load()
+	cfg, err := loadConfig()
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
 
-	var account *config.Account
-	if accountFlag != "" {
-		account, err = cfg.GetAccount(accountFlag)
-		if err != nil {
-			return nil, cobra.ShellCompDirectiveNoFileComp
-		}
-	} else {
-		account, _, err = cfg.DefaultAccount()
-		if err != nil {
-			return nil, cobra.ShellCompDirectiveNoFileComp
-		}
+	account, _, _, err := resolveAccount(cfg)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
 
-	return account.RepoPaths(), cobra.ShellCompDirectiveNoFileComp
+	// A lone directory is completed without a trailing space, so the next
+	// tab can go on to its children
+	paths := account.CompletePath(toComplete)
+	if len(paths) == 1 && len(account.CompletePath(paths[0]+".")) > 0 {
+		return paths, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+	}
+	return paths, cobra.ShellCompDirectiveNoFileComp
 }
@@ -0,0 +1,21 @@
+package commands
+
+import "testing"
+
+func TestSyncPlan(t *testing.T) {
+	cases := []struct {
+		toClone, present int
+		fetch            bool
+		want             string
+	}{
+		{3, 5, false, "3 to clone, 5 already present"},
+		{3, 5, true, "3 to clone, 5 to fetch"},
+		{0, 2, true, "2 to fetch"},
+		{4, 0, false, "4 to clone"},
+	}
+	for _, c := range cases {
+		if got := syncPlan(c.toClone, c.present, c.fetch); got != c.want {
+			t.Errorf("syncPlan(%d, %d, %v) = %q, want %q", c.toClone, c.present, c.fetch, got, c.want)
+		}
+	}
+}
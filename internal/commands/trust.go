@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/oschrenk/arbol/internal/git"
+	"github.com/spf13/cobra"
+)
+
+var trustCmd = &cobra.Command{
+	Use:   "trust <path...>",
+	Short: "Let git work in repositories owned by another user",
+	Long: `Add cloned repositories that git refuses with "detected dubious ownership"
+to git's global safe.directory list.
+
+Git refuses repositories owned by another user, which is common on shared or
+network-mounted filesystems; status then shows "dubious ownership" for them.
+This runs 'git config --global --add safe.directory <dir>' for each selected
+repo that git refuses, and leaves the others alone.
+
+Examples:
+  arbol trust work              # every refused repo under work
+  arbol trust work.backend.api  # just this one`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		account, accountName, err := getAccount()
+		if err != nil {
+			return err
+		}
+
+		repos, filters, err := selectRepos(account, accountName, args)
+		if err != nil {
+			return err
+		}
+		if len(repos) == 0 {
+			fmt.Printf("No repos found %s in account '%s'\n", describeSelection(filters), accountName)
+			return nil
+		}
+
+		ctx := cmd.Context()
+		var trusted, skipped, failed int
+		for _, repo := range repos {
+			if ctx.Err() != nil {
+				break
+			}
+			displayPath := repo.Path + "." + repo.Name
+			if !git.Exists(repo.FullPath) {
+				fmt.Printf("  skip  %s (not cloned)\n", displayPath)
+				skipped++
+				continue
+			}
+
+			opCtx, cancel := opContext(ctx)
+			_, err := git.StatusContext(opCtx, repo.FullPath, repo.Repo.RemoteName())
+			cancel()
+			if ctx.Err() != nil {
+				break
+			}
+			switch {
+			case err == nil:
+				fmt.Printf("  skip  %s (not refused by git)\n", displayPath)
+				skipped++
+				continue
+			case !errors.Is(err, git.ErrDubiousOwnership):
+				fmt.Printf("  error %s: %v\n", displayPath, err)
+				failed++
+				continue
+			}
+
+			added, err := git.Trust(repo.FullPath)
+			switch {
+			case err != nil:
+				fmt.Printf("  error %s: %v\n", displayPath, err)
+				failed++
+			case added:
+				fmt.Printf("  trust %s (%s)\n", displayPath, repo.FullPath)
+				trusted++
+			default:
+				// Listed, yet refused: safe.directory is overridden elsewhere
+				fmt.Printf("  skip  %s (already in safe.directory)\n", displayPath)
+				skipped++
+			}
+		}
+
+		var summary []string
+		if trusted > 0 {
+			summary = append(summary, fmt.Sprintf("%d trusted", trusted))
+		}
+		if skipped > 0 {
+			summary = append(summary, fmt.Sprintf("%d skipped", skipped))
+		}
+		if failed > 0 {
+			summary = append(summary, fmt.Sprintf("%d failed", failed))
+		}
+		if len(summary) == 0 {
+			summary = append(summary, "nothing to do")
+		}
+		if ctx.Err() != nil {
+			fmt.Printf("\nInterrupted, %d trusted so far\n", trusted)
+			fmt.Printf("Summary: %s\n", strings.Join(summary, ", "))
+			return errInterrupted
+		}
+		fmt.Printf("\nSummary: %s\n", strings.Join(summary, ", "))
+		if failed > 0 {
+			return fmt.Errorf("%s could not be trusted", pluralize(failed, "repo", "repos"))
+		}
+		return nil
+	},
+	ValidArgsFunction: completeRepoPath,
+}
+
+func init() {
+	trustCmd.Flags().StringArrayVar(&excludeFlags, "exclude", nil, "Skip repos matching this path or glob (repeatable)")
+	trustCmd.Flags().StringArrayVar(&tagFlags, "tag", nil, "Only repos with this tag (repeatable, all must match)")
+	trustCmd.Flags().BoolVar(&tagAnyFlag, "tag-any", false, "With several --tag, select repos with any of them")
+	trustCmd.Flags().BoolVar(&includeDisabledFlag, "include-disabled", false, "Also select repos with enabled = false")
+	rootCmd.AddCommand(trustCmd)
+}
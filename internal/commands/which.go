@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/oschrenk/arbol/internal/git"
+	"github.com/spf13/cobra"
+)
+
+var createFlag bool
+
+var whichCmd = &cobra.Command{
+	Use:   "which <path>",
+	Short: "Print the directory of a repository",
+	Long: `Print the local directory of a repository, for use in shell functions
+like cd wrappers.
+
+The path must select exactly one repo. If it matches several, they are listed
+and nothing is printed on stdout, so a wrapper never changes into the wrong
+directory.
+
+Use --create to clone the repo first if it isn't present.
+
+Examples:
+  arbol which work.backend.api            # print the directory
+  cd "$(arbol which personal.dotfiles)"   # change into it
+  arbol which work.backend.api --create   # clone first if missing`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		account, accountName, err := getAccount()
+		if err != nil {
+			return err
+		}
+
+		repo, err := resolveRepo(account, accountName, args[0])
+		if err != nil {
+			return err
+		}
+
+		if !git.Exists(repo.FullPath) {
+			if !createFlag {
+				return fmt.Errorf("%s.%s is not cloned (use --create to clone it)", repo.Path, repo.Name)
+			}
+			// stdout carries only the path, so progress goes to stderr
+			fmt.Fprintf(os.Stderr, "  clone %s.%s\n", repo.Path, repo.Name)
+			opts, err := cloneOptions(account, repo.Repo)
+			if err != nil {
+				return err
+			}
+			opCtx, cancel := opContext(cmd.Context())
+			err = git.CloneWithOptions(opCtx, repo.Repo.URL, repo.FullPath, opts)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("failed to clone %s.%s: %w", repo.Path, repo.Name, err)
+			}
+			if len(repo.Repo.PostClone) > 0 && !skipHooksFlag {
+				fmt.Fprintf(os.Stderr, "  hook  %s.%s\n", repo.Path, repo.Name)
+				if err := runHooks(cmd.Context(), repo.FullPath, repo.Repo.PostClone, nil, os.Stderr); err != nil {
+					return fmt.Errorf("%s.%s was cloned, but %w", repo.Path, repo.Name, err)
+				}
+			}
+		}
+
+		fmt.Println(repo.FullPath)
+		return nil
+	},
+	ValidArgsFunction: completeRepoPath,
+}
+
+func init() {
+	whichCmd.Flags().BoolVar(&createFlag, "create", false, "Clone the repo first if it isn't present")
+	whichCmd.Flags().BoolVar(&skipHooksFlag, "skip-hooks", false, "Don't run post_clone hooks after --create")
+	whichCmd.Flags().StringVar(&sshKeyFlag, "ssh-key", "", "Private key to clone with instead of the SSH agent (overrides ssh_key)")
+	rootCmd.AddCommand(whichCmd)
+}
@@ -1,33 +1,228 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pelletier/go-toml/v2"
 )
 
+// DefaultRemote is the remote used for ahead/behind when a repo doesn't set one
+const DefaultRemote = "origin"
+
+// DefaultPullStrategy is how pull integrates upstream when neither the repo
+// nor [settings] sets pull_strategy
+const DefaultPullStrategy = "ff-only"
+
+// PullStrategies are the values pull_strategy accepts
+var PullStrategies = []string{"ff-only", "rebase", "merge"}
+
 // Repo represents a git repository configuration
 type Repo struct {
-	URL  string `toml:"url"`
-	Name string `toml:"name,omitempty"`
+	URL          string   `toml:"url"`
+	Name         string   `toml:"name,omitempty"`
+	Remote       string   `toml:"remote,omitempty"`        // remote to compare against, defaults to origin
+	Tags         []string `toml:"tags,omitempty"`          // groups across the path tree, e.g. "ci"
+	Enabled      *bool    `toml:"enabled,omitempty"`       // false keeps the entry but skips it
+	OS           []string `toml:"os,omitempty"`            // runtime.GOOS values the repo applies on
+	Hostnames    []string `toml:"hostnames,omitempty"`     // machines the repo applies on
+	PostClone    []string `toml:"post_clone,omitempty"`    // shell commands sync runs in a fresh clone
+	Mirror       bool     `toml:"mirror,omitempty"`        // keep a bare mirror (git clone --mirror), no working tree
+	Sparse       []string `toml:"sparse,omitempty"`        // directories to check out, the rest stays out of the tree
+	Partial      bool     `toml:"partial,omitempty"`       // clone without file contents, fetched on demand (--filter=blob:none)
+	PullStrategy string   `toml:"pull_strategy,omitempty"` // ff-only, rebase or merge; defaults to [settings]
+}
+
+// IsEnabled reports whether the repo is enabled, which is the default
+func (r Repo) IsEnabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+// DirName returns the directory name of the repo: its name, or the one
+// derived from the URL
+func (r Repo) DirName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return RepoName(r.URL)
+}
+
+// SkipDisabled is the SkipReason of an applicable repo with enabled = false
+const SkipDisabled = "disabled"
+
+// SkipReason returns why the repo doesn't apply on a machine running goos
+// named hostname, or "" when it does. The os and hostnames conditions are
+// checked before enabled, so SkipDisabled means only the flag is in the way.
+func (r Repo) SkipReason(goos, hostname string) string {
+	switch {
+	case len(r.OS) > 0 && !slices.Contains(r.OS, goos):
+		return fmt.Sprintf("os is %s, repo is for %s", goos, strings.Join(r.OS, ", "))
+	case len(r.Hostnames) > 0 && !slices.Contains(r.Hostnames, hostname):
+		return fmt.Sprintf("host is %s, repo is for %s", hostname, strings.Join(r.Hostnames, ", "))
+	case !r.IsEnabled():
+		return SkipDisabled
+	}
+	return ""
+}
+
+// RemoteName returns the configured remote, falling back to DefaultRemote
+func (r Repo) RemoteName() string {
+	if r.Remote != "" {
+		return r.Remote
+	}
+	return DefaultRemote
+}
+
+// HasTags reports whether the repo carries all of tags, or with anyTag at
+// least one of them. No tags match every repo.
+func (r Repo) HasTags(tags []string, anyTag bool) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	for _, tag := range tags {
+		has := slices.Contains(r.Tags, tag)
+		if anyTag && has {
+			return true
+		}
+		if !anyTag && !has {
+			return false
+		}
+	}
+	return !anyTag
 }
 
 // Account represents a machine profile with repos
 type Account struct {
-	Default bool
-	Root    string
-	Repos   map[string][]Repo // path -> repos (path has "/" stripped)
+	Default   bool
+	Hostnames []string // machines this account is the default on
+	Root      string
+	SSHKey    string            // private key used to clone, may start with ~
+	TokenEnv  string            // env var holding the token for https clones
+	Repos     map[string][]Repo // path -> repos (path has "/" stripped)
+
+	paths []string // RepoPaths, computed on first use
 }
 
 // Config represents the full configuration file
 type Config struct {
+	Settings Settings
 	Accounts map[string]*Account
 }
 
+// Settings holds the [settings] table: preferences that apply to every
+// account
+type Settings struct {
+	PostSync      []string `toml:"post_sync,omitempty"`      // shell commands run after each sync
+	NotifyCommand string   `toml:"notify_command,omitempty"` // shell command status --notify runs instead of the OS notifier
+	LogFile       string   `toml:"log_file,omitempty"`       // history log path, may start with ~
+	PullStrategy  string   `toml:"pull_strategy,omitempty"`  // for repos that don't set one, default ff-only
+
+	// Defaults for the flags of the same name (with - for _). A flag given
+	// on the command line wins; unset ones keep the built-in default.
+	Plain       *bool  `toml:"plain,omitempty"`
+	NoColor     *bool  `toml:"no_color,omitempty"`
+	ASCII       *bool  `toml:"ascii,omitempty"`
+	PathWidth   *int   `toml:"path_width,omitempty"`
+	BranchWidth *int   `toml:"branch_width,omitempty"`
+	Fields      string `toml:"fields,omitempty"`
+	Timeout     string `toml:"timeout,omitempty"` // a duration like "30s"
+	Retries     *int   `toml:"retries,omitempty"`
+	ConfirmOver *int   `toml:"confirm_over,omitempty"`
+	Log         *bool  `toml:"log,omitempty"`
+	Jobs        *int   `toml:"jobs,omitempty"`
+	Cache       *bool  `toml:"cache,omitempty"`
+}
+
+// FlagDefaults returns the set flag defaults by flag name, formatted the way
+// they'd be given on the command line
+func (s Settings) FlagDefaults() map[string]string {
+	defaults := make(map[string]string)
+	if s.Plain != nil {
+		defaults["plain"] = strconv.FormatBool(*s.Plain)
+	}
+	if s.NoColor != nil {
+		defaults["no-color"] = strconv.FormatBool(*s.NoColor)
+	}
+	if s.ASCII != nil {
+		defaults["ascii"] = strconv.FormatBool(*s.ASCII)
+	}
+	if s.PathWidth != nil {
+		defaults["path-width"] = strconv.Itoa(*s.PathWidth)
+	}
+	if s.BranchWidth != nil {
+		defaults["branch-width"] = strconv.Itoa(*s.BranchWidth)
+	}
+	if s.Fields != "" {
+		defaults["fields"] = s.Fields
+	}
+	if s.Timeout != "" {
+		defaults["timeout"] = s.Timeout
+	}
+	if s.Retries != nil {
+		defaults["retries"] = strconv.Itoa(*s.Retries)
+	}
+	if s.Log != nil {
+		defaults["log"] = strconv.FormatBool(*s.Log)
+	}
+	if s.ConfirmOver != nil {
+		defaults["confirm-over"] = strconv.Itoa(*s.ConfirmOver)
+	}
+	if s.Jobs != nil {
+		defaults["jobs"] = strconv.Itoa(*s.Jobs)
+	}
+	if s.Cache != nil {
+		defaults["cache"] = strconv.FormatBool(*s.Cache)
+	}
+	return defaults
+}
+
+// Validate checks the settings for values no flag would accept
+func (s Settings) Validate() error {
+	if s.Timeout != "" {
+		if _, err := time.ParseDuration(s.Timeout); err != nil {
+			return &keyError{key: []string{"settings", "timeout"}, index: -1,
+				err: fmt.Errorf("invalid config: settings.timeout %q is not a duration like \"30s\"", s.Timeout)}
+		}
+	}
+	for name, value := range map[string]*int{"path_width": s.PathWidth, "branch_width": s.BranchWidth, "retries": s.Retries, "confirm_over": s.ConfirmOver} {
+		if value != nil && *value < 0 {
+			return &keyError{key: []string{"settings", name}, index: -1,
+				err: fmt.Errorf("invalid config: settings.%s must not be negative", name)}
+		}
+	}
+	if s.Jobs != nil && *s.Jobs < 1 {
+		return &keyError{key: []string{"settings", "jobs"}, index: -1,
+			err: fmt.Errorf("invalid config: settings.jobs must be at least 1")}
+	}
+	if s.PullStrategy != "" && !slices.Contains(PullStrategies, s.PullStrategy) {
+		return &keyError{key: []string{"settings", "pull_strategy"}, index: -1,
+			err: fmt.Errorf("invalid config: settings.pull_strategy %q must be one of %s", s.PullStrategy, strings.Join(PullStrategies, ", "))}
+	}
+	return nil
+}
+
+// PullStrategyFor returns how pull integrates upstream into repo: its own
+// pull_strategy, else the one in [settings], else DefaultPullStrategy
+func (s Settings) PullStrategyFor(repo Repo) string {
+	switch {
+	case repo.PullStrategy != "":
+		return repo.PullStrategy
+	case s.PullStrategy != "":
+		return s.PullStrategy
+	}
+	return DefaultPullStrategy
+}
+
 // RepoWithPath represents a repo with its full path information
 type RepoWithPath struct {
 	Repo     Repo
@@ -61,43 +256,40 @@ func LoadFromPath(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	// Parse into raw structure first
-	var raw map[string]any
-	if err := toml.Unmarshal(data, &raw); err != nil {
+	// Decode the fixed part of the schema into structs. Repos are a tree of
+	// arbitrary path segments, so they're decoded separately below.
+	var file fileConfig
+	if err := toml.Unmarshal(data, &file); err != nil {
+		var decodeErr *toml.DecodeError
+		if errors.As(err, &decodeErr) {
+			row, column := decodeErr.Position()
+			return nil, fmt.Errorf("%s:%d:%d: failed to parse config file: %w", path, row, column, err)
+		}
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	if file.Accounts == nil {
+		return nil, fmt.Errorf("invalid config: missing 'accounts' section")
+	}
 
+	src := newSource(path, data)
 	config := &Config{
+		Settings: file.Settings,
 		Accounts: make(map[string]*Account),
 	}
 
-	// Extract accounts
-	accountsRaw, ok := raw["accounts"].(map[string]any)
-	if !ok {
-		return nil, fmt.Errorf("invalid config: missing 'accounts' section")
-	}
-
-	for accountName, accountData := range accountsRaw {
-		accountMap, ok := accountData.(map[string]any)
-		if !ok {
-			continue
-		}
-
+	for accountName, fa := range file.Accounts {
 		account := &Account{
-			Repos: make(map[string][]Repo),
-		}
-
-		// Parse default and root
-		if def, ok := accountMap["default"].(bool); ok {
-			account.Default = def
-		}
-		if root, ok := accountMap["root"].(string); ok {
-			account.Root = root
+			Default:   fa.Default,
+			Hostnames: fa.Hostnames,
+			Root:      fa.Root,
+			SSHKey:    fa.SSHKey,
+			TokenEnv:  fa.TokenEnv,
+			Repos:     make(map[string][]Repo),
 		}
 
 		// Parse repos - traverse the nested structure
-		if reposRaw, ok := accountMap["repos"].(map[string]any); ok {
-			parseReposRecursive(reposRaw, "", account.Repos)
+		if err := parseReposRecursive(fa.Repos, "", []string{"accounts", accountName, "repos"}, account.Repos); err != nil {
+			return nil, src.locate(fmt.Errorf("invalid config in account %q: %w", accountName, err))
 		}
 
 		config.Accounts[accountName] = account
@@ -105,24 +297,52 @@ func LoadFromPath(path string) (*Config, error) {
 
 	// Validate config
 	if err := config.Validate(); err != nil {
-		return nil, err
+		return nil, src.locate(err)
 	}
 
 	return config, nil
 }
 
-// parseReposRecursive traverses the nested repos structure
-func parseReposRecursive(data map[string]any, prefix string, repos map[string][]Repo) {
-	for key, value := range data {
+// fileConfig mirrors the top level of the config file
+type fileConfig struct {
+	Settings Settings               `toml:"settings"`
+	Accounts map[string]fileAccount `toml:"accounts"`
+}
+
+// fileAccount mirrors an [accounts.<name>] table
+type fileAccount struct {
+	Default   bool           `toml:"default"`
+	Hostnames []string       `toml:"hostnames"`
+	Root      string         `toml:"root"`
+	SSHKey    string         `toml:"ssh_key"`
+	TokenEnv  string         `toml:"token_env"`
+	Repos     map[string]any `toml:"repos"`
+}
+
+// parseReposRecursive traverses the nested repos structure. Each key is
+// either a repo array (the path's repos) or a table of deeper path segments;
+// a "/" key holds the repos of the enclosing path. keys is the TOML key of
+// data, for locating errors. Keys are visited in sorted order so the first
+// reported error is stable.
+func parseReposRecursive(data map[string]any, prefix string, parent []string, repos map[string][]Repo) error {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
 		var currentPath string
 		if prefix == "" {
 			currentPath = key
 		} else {
 			currentPath = prefix + "." + key
 		}
+		entryKey := append(slices.Clip(parent), key)
+		tomlPath := formatKey(entryKey[2:], -1)
 
 		// Check if this is a repo array or nested path
-		switch v := value.(type) {
+		switch v := data[key].(type) {
 		case []any:
 			// This is an array of repos
 			path := currentPath
@@ -131,30 +351,76 @@ func parseReposRecursive(data map[string]any, prefix string, repos map[string][]
 				path = prefix
 			}
 
-			var repoList []Repo
-			for _, item := range v {
-				if repoMap, ok := item.(map[string]any); ok {
-					repo := Repo{}
-					if url, ok := repoMap["url"].(string); ok {
-						repo.URL = url
-					}
-					if name, ok := repoMap["name"].(string); ok {
-						repo.Name = name
-					}
-					repoList = append(repoList, repo)
+			repoList := make([]Repo, 0, len(v))
+			for i, item := range v {
+				repo, err := decodeRepo(item)
+				if err != nil {
+					return &keyError{key: entryKey, index: i, err: fmt.Errorf("%s[%d]: %w", tomlPath, i, err)}
 				}
+				repoList = append(repoList, repo)
 			}
 			repos[path] = repoList
 
 		case map[string]any:
+			if key == "/" {
+				return &keyError{key: entryKey, index: -1, err: fmt.Errorf("%s: must be an array of repos", tomlPath)}
+			}
 			// This is a nested path, recurse
-			parseReposRecursive(v, currentPath, repos)
+			if err := parseReposRecursive(v, currentPath, entryKey, repos); err != nil {
+				return err
+			}
+
+		default:
+			return &keyError{key: entryKey, index: -1, err: fmt.Errorf("%s: must be an array of repos or a table of paths, got %T", tomlPath, v)}
 		}
 	}
+	return nil
+}
+
+// decodeRepo decodes one repo entry using Repo's toml tags, rejecting
+// unknown fields (usually typos) and entries without a url
+func decodeRepo(item any) (Repo, error) {
+	var repo Repo
+	if _, ok := item.(map[string]any); !ok {
+		return repo, fmt.Errorf("repo entry must be a table like { url = \"...\" }, got %T", item)
+	}
+
+	// Round-trip through TOML so the struct tags define the accepted fields
+	data, err := toml.Marshal(item)
+	if err != nil {
+		return repo, err
+	}
+	decoder := toml.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&repo); err != nil {
+		var strict *toml.StrictMissingError
+		if errors.As(err, &strict) && len(strict.Errors) > 0 {
+			return repo, fmt.Errorf("unknown field %q", strings.Join(strict.Errors[0].Key(), "."))
+		}
+		var decodeErr *toml.DecodeError
+		if errors.As(err, &decodeErr) {
+			return repo, fmt.Errorf("invalid field %q: %s", strings.Join(decodeErr.Key(), "."), decodeErr.Error())
+		}
+		return repo, err
+	}
+
+	if repo.URL == "" {
+		return repo, fmt.Errorf("missing required field \"url\"")
+	}
+	if repo.Mirror && len(repo.Sparse) > 0 {
+		return repo, fmt.Errorf("a mirror has no working tree to make sparse, use either \"mirror\" or \"sparse\"")
+	}
+	if repo.PullStrategy != "" && !slices.Contains(PullStrategies, repo.PullStrategy) {
+		return repo, fmt.Errorf("invalid pull_strategy %q, must be one of %s", repo.PullStrategy, strings.Join(PullStrategies, ", "))
+	}
+	return repo, nil
 }
 
 // Validate checks the config for errors
 func (c *Config) Validate() error {
+	if err := c.Settings.Validate(); err != nil {
+		return err
+	}
 	for accountName, account := range c.Accounts {
 		if err := account.Validate(accountName); err != nil {
 			return err
@@ -163,8 +429,42 @@ func (c *Config) Validate() error {
 	return nil
 }
 
-// Validate checks an account for path conflicts
+// Validate checks an account for path conflicts and for repos sharing a
+// directory
 func (a *Account) Validate(accountName string) error {
+	root := ExpandPath(a.Root)
+	if path, first, second := a.findDuplicate(); path != "" {
+		repos := a.Repos[path]
+		key := a.reposKey(path)
+		return &conflictError{
+			account: accountName,
+			entries: [2]conflictEntry{
+				{key: key, index: first, what: fmt.Sprintf("is %q", repos[first].URL)},
+				{key: key, index: second, what: fmt.Sprintf("is %q", repos[second].URL)},
+			},
+			dir: fmt.Sprintf("%s/%s/%s/", root, strings.ReplaceAll(path, ".", "/"), repos[first].DirName()),
+		}
+	}
+
+	path, repoName, index := a.findConflict()
+	if path == "" {
+		return nil
+	}
+	conflictPath := path + "." + repoName
+	return &conflictError{
+		account: accountName,
+		entries: [2]conflictEntry{
+			{key: a.reposKey(path), index: index, what: fmt.Sprintf("is %q", a.Repos[path][index].URL)},
+			{key: append([]string{"repos"}, strings.Split(conflictPath, ".")...), index: -1, what: "is a path of its own"},
+		},
+		dir: fmt.Sprintf("%s/%s/", root, strings.ReplaceAll(conflictPath, ".", "/")),
+	}
+}
+
+// findConflict returns the first repo path, name, and index whose directory
+// is also a subpath (repos.<path>."/" holding a repo named like
+// repos.<path>.<name>), or "" when there is none
+func (a *Account) findConflict() (string, string, int) {
 	// Build a set of all path segments that exist as subpaths
 	subpaths := make(map[string]map[string]bool) // parent path -> set of child segments
 
@@ -180,45 +480,189 @@ func (a *Account) Validate(accountName string) error {
 		}
 	}
 
-	// Check for conflicts: repo names that match sibling path segments
-	for path, repos := range a.Repos {
+	// Check for conflicts: repo names that match sibling path segments.
+	// Paths are visited in sorted order so the reported conflict is stable.
+	paths := make([]string, 0, len(a.Repos))
+	for path := range a.Repos {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
 		siblings := subpaths[path]
 		if siblings == nil {
 			continue
 		}
 
-		for _, repo := range repos {
-			repoName := RepoName(repo.URL)
-			if repo.Name != "" {
-				repoName = repo.Name
+		for i, repo := range a.Repos[path] {
+			if repoName := repo.DirName(); siblings[repoName] {
+				return path, repoName, i
 			}
+		}
+	}
+	return "", "", 0
+}
 
-			if siblings[repoName] {
-				conflictPath := path + "." + repoName
-				return fmt.Errorf("config conflict in account %q\n  repos.%s.\"/\" contains repo %q\n  repos.%s also exists\n  Both would use path: %s/%s/",
-					accountName, path, repoName, conflictPath,
-					ExpandPath(a.Root), strings.ReplaceAll(conflictPath, ".", "/"))
+// findDuplicate returns the first repo path holding two repos with the same
+// directory name, whether the same URL listed twice or different URLs
+// ending in the same name, and the indexes of both; or "" when there is none
+func (a *Account) findDuplicate() (string, int, int) {
+	paths := make([]string, 0, len(a.Repos))
+	for path := range a.Repos {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		seen := make(map[string]int)
+		for i, repo := range a.Repos[path] {
+			if first, ok := seen[repo.DirName()]; ok {
+				return path, first, i
 			}
+			seen[repo.DirName()] = i
 		}
 	}
+	return "", 0, 0
+}
+
+// reposKey returns the TOML key that holds the repos of path: repos.<path>,
+// or repos.<path>."/" when the path also has subpaths
+func (a *Account) reposKey(path string) []string {
+	key := append([]string{"repos"}, strings.Split(path, ".")...)
+	if hasSubpaths(a, path) {
+		return append(key, "/")
+	}
+	return key
+}
 
+// AddRepo adds repo under the dotted path. It fails, leaving the account
+// unchanged, when the path already has a repo with the same directory name
+// or the repo's directory would collide with a subpath.
+func (a *Account) AddRepo(path string, repo Repo) error {
+	if repo.URL == "" {
+		return fmt.Errorf("missing required field \"url\"")
+	}
+	if path == "" || slices.Contains(strings.Split(path, "."), "") {
+		return fmt.Errorf("invalid repo path %q", path)
+	}
+	name := repo.DirName()
+	for _, existing := range a.Repos[path] {
+		if existing.DirName() == name {
+			return fmt.Errorf("%s.%s is already in the config", path, name)
+		}
+	}
+
+	if a.Repos == nil {
+		a.Repos = make(map[string][]Repo)
+	}
+	a.paths = nil
+	previous, had := a.Repos[path]
+	a.Repos[path] = append(slices.Clip(previous), repo)
+	if conflictPath, conflictName, _ := a.findConflict(); conflictPath != "" {
+		if had {
+			a.Repos[path] = previous
+		} else {
+			delete(a.Repos, path)
+		}
+		return fmt.Errorf("repo %s.%s and path %s.%s would use the same directory", conflictPath, conflictName, conflictPath, conflictName)
+	}
 	return nil
 }
 
-// DefaultAccount returns the default account and its name
+// RemoveRepo removes the repo with directory name name from the dotted path
+// and returns it. A path left without repos is dropped.
+func (a *Account) RemoveRepo(path, name string) (Repo, error) {
+	repos := a.Repos[path]
+	i := slices.IndexFunc(repos, func(r Repo) bool { return r.DirName() == name })
+	if i == -1 {
+		return Repo{}, fmt.Errorf("%s.%s is not in the config", path, name)
+	}
+	repo := repos[i]
+	a.paths = nil
+	if len(repos) == 1 {
+		delete(a.Repos, path)
+	} else {
+		a.Repos[path] = slices.Delete(slices.Clone(repos), i, i+1)
+	}
+	return repo, nil
+}
+
+// AccountReason explains why ResolveAccount chose an account
+type AccountReason string
+
+const (
+	ReasonFlag     AccountReason = "flag"         // named by --account
+	ReasonEnv      AccountReason = "env"          // named by the environment
+	ReasonHostname AccountReason = "hostname"     // lists this machine's hostname
+	ReasonDefault  AccountReason = "default"      // marked default = true
+	ReasonOnly     AccountReason = "only account" // the single configured account
+)
+
+// DefaultAccount returns the default account and its name for this machine.
+// See DefaultAccountFor.
 func (c *Config) DefaultAccount() (*Account, string, error) {
-	for name, account := range c.Accounts {
-		if account.Default {
-			return account, name, nil
+	hostname, _ := os.Hostname()
+	return c.DefaultAccountFor(hostname)
+}
+
+// DefaultAccountFor returns the default account and its name on the machine
+// with the given hostname, i.e. ResolveAccount without an explicit choice.
+func (c *Config) DefaultAccountFor(hostname string) (*Account, string, error) {
+	account, name, _, err := c.ResolveAccount("", "", hostname)
+	return account, name, err
+}
+
+// ResolveAccount picks the account to use and reports why. Candidates are
+// tried in order of precedence:
+//
+//  1. flag, an explicitly requested account name
+//  2. env, an account name from the environment
+//  3. the account whose hostnames contains hostname
+//  4. the account marked default = true
+//  5. the only account, if there is just one
+//
+// Empty flag, env, and hostname values are skipped. An unknown explicit name
+// is an error, as are several accounts matching the same hostname or being
+// marked default, since either would make the choice arbitrary.
+func (c *Config) ResolveAccount(flag, env, hostname string) (*Account, string, AccountReason, error) {
+	for _, explicit := range []struct {
+		name   string
+		reason AccountReason
+	}{{flag, ReasonFlag}, {env, ReasonEnv}} {
+		if explicit.name == "" {
+			continue
+		}
+		account, err := c.GetAccount(explicit.name)
+		if err != nil {
+			return nil, "", "", err
 		}
+		return account, explicit.name, explicit.reason, nil
 	}
-	// If no default, return first account if only one exists
-	if len(c.Accounts) == 1 {
-		for name, account := range c.Accounts {
-			return account, name, nil
+
+	var hostMatches, defaults []string
+	for _, name := range c.AccountNames() {
+		account := c.Accounts[name]
+		if hostname != "" && slices.Contains(account.Hostnames, hostname) {
+			hostMatches = append(hostMatches, name)
 		}
+		if account.Default {
+			defaults = append(defaults, name)
+		}
+	}
+
+	switch {
+	case len(hostMatches) == 1:
+		return c.Accounts[hostMatches[0]], hostMatches[0], ReasonHostname, nil
+	case len(hostMatches) > 1:
+		return nil, "", "", fmt.Errorf("multiple accounts list hostname %q: %s", hostname, strings.Join(hostMatches, ", "))
+	case len(defaults) == 1:
+		return c.Accounts[defaults[0]], defaults[0], ReasonDefault, nil
+	case len(defaults) > 1:
+		return nil, "", "", fmt.Errorf("multiple accounts are marked default: %s", strings.Join(defaults, ", "))
+	case len(c.Accounts) == 1:
+		name := c.AccountNames()[0]
+		return c.Accounts[name], name, ReasonOnly, nil
 	}
-	return nil, "", fmt.Errorf("no default account configured and multiple accounts exist")
+	return nil, "", "", fmt.Errorf("no default account configured and multiple accounts exist (%s)\nMark one with default = true, list this machine in its hostnames, or pass --account",
+		strings.Join(c.AccountNames(), ", "))
 }
 
 // GetAccount returns a specific account by name
@@ -230,9 +674,23 @@ func (c *Config) GetAccount(name string) (*Account, error) {
 	return account, nil
 }
 
-// GetRepos returns all repos for an account, optionally filtered by a dotted
-// path. See matchesFilter for the matching rules.
+// GetRepos returns the repos of an account that apply on this machine (see
+// Repo.SkipReason), optionally filtered by a dotted path. See matchesFilter
+// for the matching rules.
 func (a *Account) GetRepos(pathFilter string) []RepoWithPath {
+	hostname, _ := os.Hostname()
+	return a.getRepos(pathFilter, func(repo Repo) bool {
+		return repo.SkipReason(runtime.GOOS, hostname) == ""
+	})
+}
+
+// GetAllRepos is like GetRepos but includes disabled repos and those for
+// other machines
+func (a *Account) GetAllRepos(pathFilter string) []RepoWithPath {
+	return a.getRepos(pathFilter, func(Repo) bool { return true })
+}
+
+func (a *Account) getRepos(pathFilter string, keep func(Repo) bool) []RepoWithPath {
 	var result []RepoWithPath
 	rootPath := ExpandPath(a.Root)
 
@@ -241,11 +699,10 @@ func (a *Account) GetRepos(pathFilter string) []RepoWithPath {
 		dirPath := strings.ReplaceAll(path, ".", string(filepath.Separator))
 
 		for _, repo := range repos {
-			name := RepoName(repo.URL)
-			if repo.Name != "" {
-				name = repo.Name
+			if !keep(repo) {
+				continue
 			}
-
+			name := repo.DirName()
 			if !matchesFilter(path, name, pathFilter) {
 				continue
 			}
@@ -275,14 +732,49 @@ func (a *Account) GetRepos(pathFilter string) []RepoWithPath {
 //
 // Matching is segment-aware, so "timewax.backend" matches only the backend
 // repo, not siblings like "timewax.all-node-apps".
+//
+// A filter containing glob characters (*, ?, [) is matched with path.Match,
+// treating "." like "/", against the repo and each of its ancestors, so
+// "work.*" selects every repo under work and "*.api" every api repo one
+// level down.
 func matchesFilter(container, name, filter string) bool {
+	return MatchesPath(container+"."+name, filter)
+}
+
+// MatchesPath reports whether the dotted path id (a repo, or a directory
+// under the root) is selected by filter. See matchesFilter for the rules.
+func MatchesPath(id, filter string) bool {
 	if filter == "" {
 		return true
 	}
-	full := container + "." + name
-	return full == filter ||
-		container == filter ||
-		strings.HasPrefix(container, filter+".")
+	if !IsGlob(filter) {
+		return id == filter || strings.HasPrefix(id, filter+".")
+	}
+
+	pattern := strings.ReplaceAll(filter, ".", "/")
+	segments := strings.Split(id, ".")
+	for i := range segments {
+		if ok, _ := path.Match(pattern, strings.Join(segments[:i+1], "/")); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// IsGlob reports whether filter contains glob characters
+func IsGlob(filter string) bool {
+	return strings.ContainsAny(filter, "*?[")
+}
+
+// CheckFilter returns an error if filter is a malformed glob
+func CheckFilter(filter string) error {
+	if !IsGlob(filter) {
+		return nil
+	}
+	if _, err := path.Match(strings.ReplaceAll(filter, ".", "/"), ""); err != nil {
+		return fmt.Errorf("invalid pattern '%s': %w", filter, err)
+	}
+	return nil
 }
 
 // AccountNames returns a list of all account names
@@ -295,36 +787,105 @@ func (c *Config) AccountNames() []string {
 	return names
 }
 
-// RepoPaths returns all unique repo paths for an account
+// RepoPaths returns every level of the account's tree, sorted: each path,
+// the directories above it, and each repo below it, so "work.backend" with
+// repo api gives "work", "work.backend", and "work.backend.api". The result
+// is computed once and shared until AddRepo or RemoveRepo changes the
+// account; callers must not modify it.
 func (a *Account) RepoPaths() []string {
-	var paths []string
-	seen := make(map[string]bool)
+	if a.paths != nil {
+		return a.paths
+	}
 
-	for path, repos := range a.Repos {
-		if !seen[path] {
+	size := len(a.Repos)
+	for _, repos := range a.Repos {
+		size += len(repos)
+	}
+	seen := make(map[string]struct{}, size)
+	paths := make([]string, 0, size)
+	add := func(path string) {
+		if _, ok := seen[path]; !ok {
+			seen[path] = struct{}{}
 			paths = append(paths, path)
-			seen[path] = true
 		}
-		// Also add individual repo paths
-		for _, repo := range repos {
-			name := RepoName(repo.URL)
-			if repo.Name != "" {
-				name = repo.Name
-			}
-			fullPath := path + "." + name
-			if !seen[fullPath] {
-				paths = append(paths, fullPath)
-				seen[fullPath] = true
+	}
+
+	for path, repos := range a.Repos {
+		// The directories above path: "work" for "work.backend"
+		for i := range len(path) {
+			if path[i] == '.' {
+				add(path[:i])
 			}
 		}
+		add(path)
+		for _, repo := range repos {
+			add(path + "." + repo.DirName())
+		}
 	}
 
 	sort.Strings(paths)
+	a.paths = paths
 	return paths
 }
 
+// CompletePath returns the paths shell completion offers for prefix: the
+// children of the last full segment of prefix that start with what follows
+// it, both subpaths and repos. "work." gives "work.backend" and "work.docs",
+// "work.b" just "work.backend", and "" the top-level paths.
+func (a *Account) CompletePath(prefix string) []string {
+	depth := strings.Count(prefix, ".")
+	var matches []string
+	for _, path := range a.RepoPaths() {
+		if strings.HasPrefix(path, prefix) && strings.Count(path, ".") == depth {
+			matches = append(matches, path)
+		}
+	}
+	return matches
+}
+
+// ExpandAbbrev returns the paths an abbreviated path can stand for. Each
+// dotted segment of abbrev is matched as a prefix of the corresponding
+// segment, against every repo and every level of the tree, so "w.ba" finds
+// "work.backend". A path that exists as written is returned on its own.
+// Globs aren't abbreviations and return nothing.
+func (a *Account) ExpandAbbrev(abbrev string) []string {
+	if abbrev == "" || IsGlob(abbrev) {
+		return nil
+	}
+
+	known := a.RepoPaths()
+	if _, found := slices.BinarySearch(known, abbrev); found {
+		return []string{abbrev}
+	}
+
+	parts := strings.Split(abbrev, ".")
+	var matches []string
+	for _, path := range known {
+		segments := strings.Split(path, ".")
+		if len(segments) != len(parts) {
+			continue
+		}
+		match := true
+		for i, part := range parts {
+			if !strings.HasPrefix(segments[i], part) {
+				match = false
+				break
+			}
+		}
+		if match {
+			matches = append(matches, path)
+		}
+	}
+
+	return matches
+}
+
 // ExpandPath expands ~ to home directory
 func ExpandPath(path string) string {
+	if path == "~" {
+		home, _ := os.UserHomeDir()
+		return home
+	}
 	if strings.HasPrefix(path, "~/") {
 		home, _ := os.UserHomeDir()
 		return filepath.Join(home, path[2:])
@@ -332,9 +893,35 @@ func ExpandPath(path string) string {
 	return path
 }
 
-// RepoName extracts the repository name from a git URL
+// RepoName extracts the repository name from a git URL: the last path
+// component without ".git". Handles scp-style (git@host:group/repo.git),
+// scheme URLs (https://, ssh://, git://, file://) including ports, query
+// strings, fragments, and trailing slashes, and plain local paths.
 func RepoName(url string) string {
-	// Handle both git@github.com:user/repo.git and https://github.com/user/repo.git
-	base := filepath.Base(url)
-	return strings.TrimSuffix(base, ".git")
+	u := strings.TrimSpace(url)
+
+	// Drop query string and fragment
+	if i := strings.IndexAny(u, "?#"); i != -1 {
+		u = u[:i]
+	}
+
+	if i := strings.Index(u, "://"); i != -1 {
+		// scheme://[user@]host[:port]/path - keep only the path
+		u = u[i+3:]
+		if slash := strings.Index(u, "/"); slash != -1 {
+			u = u[slash:]
+		} else {
+			u = ""
+		}
+	} else if colon := strings.Index(u, ":"); colon != -1 && !strings.Contains(u[:colon], "/") {
+		// scp-like [user@]host:path. A colon after a slash is part of a
+		// local path instead.
+		u = u[colon+1:]
+	}
+
+	u = strings.TrimRight(u, "/")
+	if i := strings.LastIndex(u, "/"); i != -1 {
+		u = u[i+1:]
+	}
+	return strings.TrimSuffix(u, ".git")
 }
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Encode writes the config as TOML that LoadFromPath reads back: settings
+// first, then accounts sorted by name. Comments and formatting of a file it
+// was loaded from are not preserved.
+func (c *Config) Encode(w io.Writer) error {
+	settings, err := toml.Marshal(c.Settings)
+	if err != nil {
+		return fmt.Errorf("failed to encode settings: %w", err)
+	}
+	hasSettings := len(bytes.TrimSpace(settings)) > 0
+	if hasSettings {
+		if _, err := fmt.Fprintf(w, "[settings]\n%s", settings); err != nil {
+			return err
+		}
+	}
+
+	names := c.AccountNames()
+	for i, name := range names {
+		if i > 0 || hasSettings {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if err := EncodeAccount(w, name, c.Accounts[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Save validates the config and writes it to path in Encode's layout,
+// creating the directory if needed. The file is replaced atomically, so a
+// failed save leaves the previous contents in place.
+func (c *Config) Save(path string) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	if err := c.Encode(&b); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(dir, ".config-*.toml")
+	if err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// EncodeAccount writes one account as an [accounts.<name>] table. Repos are
+// written as dotted keys, sorted by path, one inline table per repo. A path
+// that also has subpaths gets its repos under the "/" key, the layout
+// parseReposRecursive expects.
+func EncodeAccount(w io.Writer, name string, account *Account) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[accounts.%s]\n", tomlKey(name))
+	if account.Default {
+		b.WriteString("default = true\n")
+	}
+	if len(account.Hostnames) > 0 {
+		value, err := tomlValue(account.Hostnames)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "hostnames = %s\n", value)
+	}
+	root, err := tomlValue(account.Root)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(&b, "root = %s\n", root)
+	if account.SSHKey != "" {
+		key, err := tomlValue(account.SSHKey)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "ssh_key = %s\n", key)
+	}
+	if account.TokenEnv != "" {
+		env, err := tomlValue(account.TokenEnv)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "token_env = %s\n", env)
+	}
+
+	paths := make([]string, 0, len(account.Repos))
+	for path := range account.Repos {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		repos := account.Repos[path]
+		if len(repos) == 0 {
+			continue
+		}
+
+		key := "repos"
+		for _, segment := range strings.Split(path, ".") {
+			key += "." + tomlKey(segment)
+		}
+		if hasSubpaths(account, path) {
+			key += `."/"`
+		}
+
+		fmt.Fprintf(&b, "\n%s = [\n", key)
+		for _, repo := range repos {
+			table, err := inlineTable(repo)
+			if err != nil {
+				return fmt.Errorf("failed to encode repo %s in %s: %w", repo.URL, path, err)
+			}
+			fmt.Fprintf(&b, "  %s,\n", table)
+		}
+		b.WriteString("]\n")
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// hasSubpaths reports whether any other repo path of account lies under path
+func hasSubpaths(account *Account, path string) bool {
+	for other, repos := range account.Repos {
+		if len(repos) > 0 && strings.HasPrefix(other, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// tomlKey returns segment as a TOML key, quoted unless it's a bare key
+func tomlKey(segment string) string {
+	if bareKey.MatchString(segment) {
+		return segment
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(segment) + `"`
+}
+
+// tomlValue renders a single value the way go-toml writes it
+func tomlValue(v any) (string, error) {
+	data, err := toml.Marshal(map[string]any{"v": v})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(string(data), "v = "), "\n"), nil
+}
+
+// inlineTable renders repo as a TOML inline table. Going through toml.Marshal
+// keeps it in step with the struct tags decodeRepo reads.
+func inlineTable(repo Repo) (string, error) {
+	data, err := toml.Marshal(repo)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	return "{ " + strings.Join(lines, ", ") + " }", nil
+}
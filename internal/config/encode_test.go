@@ -0,0 +1,120 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeAccount(t *testing.T) {
+	account := &Account{
+		Default: true,
+		Root:    "~/Projects",
+		Repos: map[string][]Repo{
+			"personal":         {{URL: "git@github.com:me/dotfiles.git"}},
+			"personal.golang":  {{URL: "git@github.com:me/app.git", Name: "my-app"}},
+			"work.backend":     {{URL: "git@github.com:company/api.git", Remote: "upstream"}},
+			"clients.acme.com": {{URL: "https://git.acme.com/site.git"}},
+		},
+	}
+
+	var b strings.Builder
+	if err := EncodeAccount(&b, "default", account); err != nil {
+		t.Fatal(err)
+	}
+	want := `[accounts.default]
+default = true
+root = '~/Projects'
+
+repos.clients.acme.com = [
+  { url = 'https://git.acme.com/site.git' },
+]
+
+repos.personal."/" = [
+  { url = 'git@github.com:me/dotfiles.git' },
+]
+
+repos.personal.golang = [
+  { url = 'git@github.com:me/app.git', name = 'my-app' },
+]
+
+repos.work.backend = [
+  { url = 'git@github.com:company/api.git', remote = 'upstream' },
+]
+`
+	if got := b.String(); got != want {
+		t.Errorf("EncodeAccount() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	path := writeConfig(t, `
+[settings]
+post_sync = ["echo done", "make -C ~/index"]
+notify_command = "notify-send \"$ARBOL_NOTIFY_TITLE\" \"$ARBOL_NOTIFY_MESSAGE\""
+
+[accounts.laptop]
+default = true
+hostnames = ["mbp"]
+root = "~/Projects"
+repos.personal."/" = [{ url = "git@github.com:me/dotfiles.git" }]
+repos.personal.golang = [{ url = "git@github.com:me/app.git", name = "app-svc", tags = ["ci", "go"] }]
+repos."with space" = [{ url = "git@github.com:me/odd.git", os = ["darwin"], hostnames = ["mbp"] }]
+
+[accounts.spare]
+root = "/srv/git"
+ssh_key = "~/.ssh/deploy_ed25519"
+token_env = "SPARE_TOKEN"
+repos.mirror = [{ url = "https://example.com/x.git", remote = "upstream" }, { url = "https://example.com/old.git", enabled = false }]
+`)
+	cfg, err := LoadFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := cfg.Encode(&b); err != nil {
+		t.Fatal(err)
+	}
+	again, err := LoadFromPath(writeConfig(t, b.String()))
+	if err != nil {
+		t.Fatalf("reloading encoded config: %v\n%s", err, b.String())
+	}
+	if !reflect.DeepEqual(cfg, again) {
+		t.Errorf("round trip changed the config:\n%s", b.String())
+	}
+}
+
+func TestSaveRoundTrip(t *testing.T) {
+	cfg, err := LoadFromPath(writeConfig(t, `
+[accounts.home]
+default = true
+root = "~/Projects"
+repos.personal = [{ url = "git@github.com:me/dotfiles.git" }]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := cfg.Accounts["home"]
+	if err := account.AddRepo("personal.golang", Repo{URL: "git@github.com:me/app.git", Tags: []string{"go"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "nested", "config.toml")
+	if err := cfg.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	again, err := LoadFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, again) {
+		t.Errorf("Save/Load changed the config: %+v", again.Accounts["home"].Repos)
+	}
+	// personal now has a subpath, so its own repos moved under "/"
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), `repos.personal."/" = [`) {
+		t.Errorf("expected nested layout, got\n%s", data)
+	}
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+// source locates keys in the config file being loaded, so errors about its
+// values can say which line to fix
+type source struct {
+	path  string
+	lines map[string]int // joined key -> line it's set on
+}
+
+// keyError is an error about the value at a TOML key. LoadFromPath prefixes
+// it with the file and line of the key.
+type keyError struct {
+	key   []string // e.g. accounts, home, repos, work
+	index int      // of the entry in the key's array, -1 for the key itself
+	err   error
+}
+
+func (e *keyError) Error() string {
+	return e.err.Error()
+}
+
+func (e *keyError) Unwrap() error {
+	return e.err
+}
+
+// conflictError is two config entries that would share a directory
+type conflictError struct {
+	account string
+	entries [2]conflictEntry
+	dir     string
+}
+
+// conflictEntry is one side of a conflictError
+type conflictEntry struct {
+	key   []string // under the account, e.g. repos, work, /
+	index int      // of the repo in the key's array, -1 for the key itself
+	what  string   // e.g. is "git@github.com:company/api.git"
+	line  int      // in the config file, 0 if unknown
+}
+
+func (e *conflictError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "config conflict in account %q", e.account)
+	for _, entry := range e.entries {
+		fmt.Fprintf(&b, "\n  %s %s", formatKey(entry.key, entry.index), entry.what)
+		if entry.line > 0 {
+			fmt.Fprintf(&b, " (line %d)", entry.line)
+		}
+	}
+	fmt.Fprintf(&b, "\n  Both would use path: %s", e.dir)
+	return b.String()
+}
+
+// formatKey writes key the way it appears in a config file, e.g.
+// repos.work."/"[2]
+func formatKey(key []string, index int) string {
+	parts := make([]string, len(key))
+	for i, segment := range key {
+		parts[i] = tomlKey(segment)
+	}
+	formatted := strings.Join(parts, ".")
+	if index >= 0 {
+		formatted += fmt.Sprintf("[%d]", index)
+	}
+	return formatted
+}
+
+// newSource indexes the keys of the config file at path holding data. Array
+// entries are indexed too, so a repo's line is that of its own { url = ... }.
+// A document that doesn't parse yields no lines.
+func newSource(path string, data []byte) *source {
+	s := &source{path: path, lines: make(map[string]int)}
+	var p unstable.Parser
+	p.Reset(data)
+
+	var table []string
+	arrayTables := make(map[string]int) // [[key]] -> entries so far
+	for p.NextExpression() {
+		expr := p.Expression()
+		switch expr.Kind {
+		case unstable.Table:
+			table = nodeKey(expr)
+			s.record(&p, table, -1, expr.Child())
+		case unstable.ArrayTable:
+			key := nodeKey(expr)
+			index := arrayTables[joinKey(key, -1)]
+			arrayTables[joinKey(key, -1)]++
+			s.record(&p, key, index, expr.Child())
+			table = append(key, strconv.Itoa(index))
+		case unstable.KeyValue:
+			s.keyValue(&p, table, expr)
+		}
+	}
+	return s
+}
+
+// keyValue indexes a key/value pair under table, and the entries of an
+// array or inline table value
+func (s *source) keyValue(p *unstable.Parser, table []string, kv *unstable.Node) {
+	key := append(append([]string(nil), table...), nodeKey(kv)...)
+	s.record(p, key, -1, kv.Child().Next())
+
+	value := kv.Value()
+	switch value.Kind {
+	case unstable.Array:
+		index := 0
+		for it := value.Children(); it.Next(); {
+			entry := it.Node()
+			s.record(p, key, index, entry)
+			if entry.Kind == unstable.InlineTable {
+				s.inlineTable(p, append(key, strconv.Itoa(index)), entry)
+			}
+			index++
+		}
+	case unstable.InlineTable:
+		s.inlineTable(p, key, value)
+	}
+}
+
+// inlineTable indexes the keys of an inline table at key
+func (s *source) inlineTable(p *unstable.Parser, key []string, table *unstable.Node) {
+	for it := table.Children(); it.Next(); {
+		if kv := it.Node(); kv.Kind == unstable.KeyValue {
+			s.keyValue(p, key, kv)
+		}
+	}
+}
+
+// record notes the line of node as that of key, or its entry at index,
+// keeping the first line when a key is seen again
+func (s *source) record(p *unstable.Parser, key []string, index int, node *unstable.Node) {
+	if node == nil || node.Raw.Length == 0 {
+		return
+	}
+	joined := joinKey(key, index)
+	if _, ok := s.lines[joined]; !ok {
+		s.lines[joined] = p.Shape(node.Raw).Start.Line
+	}
+}
+
+// line returns the line key, or its entry at index, is set on; 0 if unknown
+func (s *source) line(key []string, index int) int {
+	if s == nil {
+		return 0
+	}
+	return s.lines[joinKey(key, index)]
+}
+
+// locate prefixes a keyError or conflictError in err with the file and line
+// of its key, and fills in the lines of the conflicting entries
+func (s *source) locate(err error) error {
+	var keyErr *keyError
+	if errors.As(err, &keyErr) {
+		if line := s.line(keyErr.key, keyErr.index); line > 0 {
+			return fmt.Errorf("%s:%d: %w", s.path, line, err)
+		}
+		return err
+	}
+	var conflict *conflictError
+	if errors.As(err, &conflict) {
+		for i, entry := range conflict.entries {
+			conflict.entries[i].line = s.line(append([]string{"accounts", conflict.account}, entry.key...), entry.index)
+		}
+		if line := conflict.entries[0].line; line > 0 {
+			return fmt.Errorf("%s:%d: %w", s.path, line, err)
+		}
+	}
+	return err
+}
+
+// nodeKey returns the segments of a table header's or key/value's key
+func nodeKey(node *unstable.Node) []string {
+	var key []string
+	for it := node.Key(); it.Next(); {
+		key = append(key, string(it.Node().Data))
+	}
+	return key
+}
+
+// joinKey joins key segments, and the entry index if any, into a map key.
+// They're joined with NUL rather than dots, so segments with dots stay
+// distinct.
+func joinKey(key []string, index int) string {
+	joined := strings.Join(key, "\x00")
+	if index >= 0 {
+		joined += "\x00" + strconv.Itoa(index)
+	}
+	return joined
+}
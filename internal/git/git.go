@@ -5,27 +5,38 @@
 // This package shells out to the git CLI for status operations rather than
 // using go-git's pure Go implementation. This is significantly faster because:
 //
-//   - git status --porcelain: Uses git's optimized filesystem caching and stat
-//     info rather than walking every file in Go
+//   - git status --porcelain=v2 --branch: Uses git's optimized filesystem
+//     caching and stat info rather than walking every file in Go, and reports
+//     the branch and its ahead/behind counts in the same call
 //   - git rev-list --count: Uses git's native graph algorithms to count commits
 //     between refs, avoiding expensive ancestor traversal in Go
 //
 // go-git is still used for Clone (benefits from its SSH agent handling) and
-// Exists (simple check).
+// Exists (simple check). Kinds of clone go-git doesn't support, like
+// mirrors, run git clone instead.
 package git
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	cryptossh "golang.org/x/crypto/ssh"
 )
 
 // RepoStatus represents the status of a git repository
@@ -34,32 +45,210 @@ type RepoStatus struct {
 	IsDetached     bool // true if HEAD is detached (Branch will be short hash)
 	IsDirty        bool
 	DirtyFiles     int
-	Behind         int       // commits current branch is behind origin
-	Ahead          int       // commits current branch is ahead of origin (unpushed)
+	Remote         string    // remote that Ahead/Behind are compared against
+	Behind         int       // commits current branch is behind the remote
+	Ahead          int       // commits current branch is ahead of the remote (unpushed)
 	NoTracking     bool      // true if no remote tracking branch
+	RemoteSkipped  bool      // true if Ahead/Behind weren't computed (StatusOptions.SkipRemote)
 	LastCommitTime time.Time // time of the most recent commit
+	Author         string    // author name of the most recent commit
+	Subject        string    // subject line of the most recent commit
+	LastFetch      time.Time // when the repo was last fetched, zero if never
+	Operation      string    // in-progress operation (OperationRebase, ...), empty if none
+	RemoteURL      string    // URL of the origin remote, empty if unset
+	IsBare         bool      // true for a bare repository, which has no worktree to be dirty or branch to compare
+}
+
+// CloneRemote is the remote a clone's URL is recorded under
+const CloneRemote = "origin"
+
+// In-progress operations that leave a repository in a fragile state
+const (
+	OperationRebase     = "rebase"
+	OperationMerge      = "merge"
+	OperationCherryPick = "cherry-pick"
+)
+
+// operationMarkers maps files/directories inside the git dir to the operation
+// they indicate, in order of precedence
+var operationMarkers = []struct {
+	name      string
+	operation string
+}{
+	{"rebase-merge", OperationRebase},
+	{"rebase-apply", OperationRebase},
+	{"MERGE_HEAD", OperationMerge},
+	{"CHERRY_PICK_HEAD", OperationCherryPick},
+}
+
+// ErrTimeout is returned when an operation exceeds its context deadline
+var ErrTimeout = errors.New("timed out")
+
+// ErrDubiousOwnership is returned by Status when git refuses a repository
+// owned by another user, as on shared or network-mounted filesystems, until
+// it's listed in safe.directory (see Trust)
+var ErrDubiousOwnership = errors.New("dubious ownership: owned by another user")
+
+// contextError reports why a command was stopped when its context is done,
+// since a killed process only says "signal: killed". Otherwise err is
+// returned unchanged.
+func contextError(ctx context.Context, err error) error {
+	switch ctx.Err() {
+	case nil:
+		return err
+	case context.DeadlineExceeded:
+		return ErrTimeout
+	default:
+		return ctx.Err()
+	}
 }
 
 // Clone clones a git repository to the specified path
 func Clone(url, path string) error {
+	return CloneContext(context.Background(), url, path)
+}
+
+// CloneContext is like Clone but stops when ctx is done. A clone that fails
+// or is interrupted has its partially written target directory removed, so a
+// later sync retries it rather than finding an existing (broken) repo.
+func CloneContext(ctx context.Context, url, path string) error {
+	return CloneWithOptions(ctx, url, path, CloneOptions{})
+}
+
+// CloneOptions configures how CloneWithOptions authenticates and what kind
+// of clone it makes. SSH URLs use the key settings, https URLs the token.
+type CloneOptions struct {
+	SSHKey     string   // private key file; empty uses the SSH agent or a default key
+	Passphrase string   // passphrase for an encrypted SSHKey
+	Token      string   // access token for https URLs; empty clones anonymously
+	Mirror     bool     // a bare mirror (git clone --mirror) instead of a working tree
+	Sparse     []string // check out only these directories (git sparse-checkout)
+	Partial    bool     // leave file contents on the server until needed (--filter=blob:none)
+}
+
+// needsGit reports whether the clone opts ask for is beyond go-git, so git
+// itself has to run it
+func (opts CloneOptions) needsGit() bool {
+	return opts.Mirror || len(opts.Sparse) > 0 || opts.Partial
+}
+
+// CloneWithOptions is like CloneContext but authenticates and clones as
+// opts says. Mirrors, sparse checkouts and partial clones are cloned by
+// running git, see cloneWithGit; everything else by go-git.
+func CloneWithOptions(ctx context.Context, url, path string, opts CloneOptions) error {
+	if opts.needsGit() {
+		return cloneWithGit(ctx, url, path, opts)
+	}
+	auth, err := cloneAuth(url, opts)
+	if err != nil {
+		return err
+	}
+
+	_, statErr := os.Stat(path)
+	created := os.IsNotExist(statErr)
+
 	// Ensure parent directory exists
 	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return err
 	}
 
-	// Get SSH authentication
-	auth := getSSHAuth()
-
-	_, err := git.PlainClone(path, false, &git.CloneOptions{
+	if trace != nil {
+		fmt.Fprintf(trace, "+ clone %s %s  (go-git)\n", url, path)
+	}
+	_, err = git.PlainCloneContext(ctx, path, false, &git.CloneOptions{
 		URL:  url,
 		Auth: auth,
 	})
+	if err != nil {
+		traceFailure(err, "")
+	}
+	if err != nil && created {
+		os.RemoveAll(path)
+	}
+	if ctx.Err() != nil {
+		return contextError(ctx, err)
+	}
 	if err != nil {
 		return friendlyCloneError(url, err)
 	}
 	return nil
 }
 
+// cloneWithGit clones by running git clone, for the kinds of clone go-git
+// can't make. Authentication follows opts like cloneAuth: an SSH key through
+// GIT_SSH_COMMAND, a token as an http.extraHeader passed in the environment
+// so it stays out of the process list. git can't be handed a key's
+// passphrase, so an encrypted key has to be in the SSH agent.
+func cloneWithGit(ctx context.Context, url, path string, opts CloneOptions) error {
+	_, statErr := os.Stat(path)
+	created := os.IsNotExist(statErr)
+
+	parent := filepath.Dir(path)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return err
+	}
+
+	var env []string
+	switch {
+	case IsHTTPURL(url) && opts.Token != "":
+		credentials := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + opts.Token))
+		env = append(env, "GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic "+credentials)
+	case !IsHTTPURL(url) && opts.SSHKey != "":
+		env = append(env, "GIT_SSH_COMMAND=ssh -i "+ShellQuote(opts.SSHKey)+" -o IdentitiesOnly=yes")
+	}
+
+	args := []string{"clone", "--quiet"}
+	switch {
+	case opts.Mirror:
+		args = append(args, "--mirror")
+	case len(opts.Sparse) > 0:
+		// Checks out only the top-level files until the directories are set
+		args = append(args, "--sparse")
+	}
+	if opts.Partial {
+		// History comes down in full, file contents as checkouts need them
+		args = append(args, "--filter=blob:none")
+	}
+	args = append(args, "--", url, path)
+	_, err := gitCommandEnv(ctx, parent, env, args...)
+	if err == nil && !opts.Mirror && len(opts.Sparse) > 0 {
+		_, err = gitCommand(ctx, path, append([]string{"sparse-checkout", "set", "--"}, opts.Sparse...)...)
+	}
+	if err != nil && created {
+		os.RemoveAll(path)
+	}
+	if ctx.Err() != nil {
+		return contextError(ctx, err)
+	}
+	return err
+}
+
+// ShellQuote quotes s for sh, which runs GIT_SSH_COMMAND and export scripts
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// cloneAuth picks the auth method for url's scheme: token basic auth for
+// https, SSH otherwise
+func cloneAuth(url string, opts CloneOptions) (transport.AuthMethod, error) {
+	if IsHTTPURL(url) {
+		if opts.Token == "" {
+			return nil, nil
+		}
+		// GitHub wants this username for tokens, GitLab accepts any
+		return &http.BasicAuth{Username: "x-access-token", Password: opts.Token}, nil
+	}
+	return getSSHAuth(opts.SSHKey, opts.Passphrase)
+}
+
+// IsHTTPURL reports whether url is cloned over http(s) rather than SSH or
+// the filesystem
+func IsHTTPURL(url string) bool {
+	lower := strings.ToLower(url)
+	return strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "http://")
+}
+
 // friendlyCloneError translates low-level go-git/network errors into messages
 // that point at the likely cause (an unreachable host or failed auth).
 func friendlyCloneError(url string, err error) error {
@@ -75,24 +264,28 @@ func friendlyCloneError(url string, err error) error {
 		strings.Contains(msg, "i/o timeout"),
 		strings.Contains(msg, "connection timed out"),
 		strings.Contains(msg, "no such host"):
-		return fmt.Errorf("cannot reach %s (check your network connection): %w", hostFromURL(url), err)
+		return fmt.Errorf("cannot reach %s (check your network connection): %w", HostFromURL(url), err)
 
 	// Authentication failures.
 	case strings.Contains(msg, "auth"),
 		strings.Contains(msg, "permission denied"),
 		strings.Contains(msg, "handshake failed"),
 		strings.Contains(msg, "unable to authenticate"):
-		return fmt.Errorf("authentication failed for %s (is your SSH key/agent set up?): %w", hostFromURL(url), err)
+		hint := "is your SSH key/agent set up?"
+		if IsHTTPURL(url) {
+			hint = "is an access token set?"
+		}
+		return fmt.Errorf("authentication failed for %s (%s): %w", HostFromURL(url), hint, err)
 
 	default:
 		return err
 	}
 }
 
-// hostFromURL extracts a human-readable host from a git URL, supporting both
+// HostFromURL extracts a human-readable host from a git URL, supporting both
 // scp-like syntax (git@host:path) and URL syntax (ssh://host/path). Falls back
 // to the full URL if no host can be parsed.
-func hostFromURL(url string) string {
+func HostFromURL(url string) string {
 	// scp-like: git@host:path
 	if at := strings.Index(url, "@"); at != -1 {
 		rest := url[at+1:]
@@ -112,151 +305,1327 @@ func hostFromURL(url string) string {
 	return url
 }
 
-// getSSHAuth returns SSH authentication, trying agent first then key files
-func getSSHAuth() transport.AuthMethod {
+// WebURL converts a git remote URL to the https URL of the repository's web
+// page, e.g. git@github.com:user/repo.git -> https://github.com/user/repo.
+// Any host is mapped the same way, so self-hosted GitLab/Gitea instances work
+// as long as they serve the web UI on the same host. Local repositories have
+// no web page and return an error.
+func WebURL(url string) (string, error) {
+	u := strings.TrimSuffix(strings.TrimSpace(url), "/")
+	u = strings.TrimSuffix(u, ".git")
+
+	var host, path string
+	if idx := strings.Index(u, "://"); idx != -1 {
+		scheme := u[:idx]
+		if scheme == "file" {
+			return "", fmt.Errorf("%s is a local repository", url)
+		}
+		host, path, _ = strings.Cut(u[idx+3:], "/")
+		if at := strings.LastIndex(host, "@"); at != -1 {
+			host = host[at+1:]
+		}
+		// SSH ports don't carry over to the web server; keep explicit
+		// http(s) ports
+		if scheme != "http" && scheme != "https" {
+			host, _, _ = strings.Cut(host, ":")
+		}
+		if scheme == "http" {
+			return "http://" + host + "/" + path, nil
+		}
+	} else if colon := strings.Index(u, ":"); colon != -1 && !strings.Contains(u[:colon], "/") {
+		// scp-like: [user@]host:path
+		host, path = u[:colon], strings.TrimPrefix(u[colon+1:], "/")
+		if at := strings.LastIndex(host, "@"); at != -1 {
+			host = host[at+1:]
+		}
+	} else {
+		return "", fmt.Errorf("%s is a local repository", url)
+	}
+
+	if host == "" || path == "" {
+		return "", fmt.Errorf("cannot derive a web URL from %s", url)
+	}
+	return "https://" + host + "/" + path, nil
+}
+
+// Submodule is an entry of a .gitmodules file
+type Submodule struct {
+	Name string
+	Path string // directory relative to the superproject
+	URL  string // as written, possibly relative (../lib.git)
+}
+
+// Submodules reads the entries of a .gitmodules file, in file order. The
+// file is parsed by git itself, so quoting and includes behave as in git.
+func Submodules(file string) ([]Submodule, error) {
+	output, err := gitCommand(context.Background(), filepath.Dir(file),
+		"config", "--file", file, "--get-regexp", `^submodule\..*\.(path|url)$`)
+	if err != nil {
+		// git config exits 1 when nothing matches
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+
+	var modules []Submodule
+	index := make(map[string]int)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		key, value, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		// Names may contain dots, so cut the fixed prefix and suffix
+		key = strings.TrimPrefix(key, "submodule.")
+		dot := strings.LastIndex(key, ".")
+		if dot == -1 {
+			continue
+		}
+		name, field := key[:dot], key[dot+1:]
+		i, seen := index[name]
+		if !seen {
+			i = len(modules)
+			index[name] = i
+			modules = append(modules, Submodule{Name: name})
+		}
+		switch field {
+		case "path":
+			modules[i].Path = value
+		case "url":
+			modules[i].URL = value
+		}
+	}
+	return modules, nil
+}
+
+// ResolveSubmoduleURL resolves a relative submodule URL (./x or ../x)
+// against the superproject's URL the way git does: each ../ drops one path
+// component of base, so ../lib.git next to git@github.com:org/app.git is
+// git@github.com:org/lib.git. Absolute URLs are returned unchanged.
+func ResolveSubmoduleURL(base, url string) string {
+	if !strings.HasPrefix(url, "./") && !strings.HasPrefix(url, "../") {
+		return url
+	}
+	base = strings.TrimSuffix(base, "/")
+	for {
+		if rest, ok := strings.CutPrefix(url, "./"); ok {
+			url = rest
+			continue
+		}
+		if rest, ok := strings.CutPrefix(url, "../"); ok {
+			url = rest
+			// Drop the last component, stopping at the host of scp-like
+			// (host:path) and scheme URLs
+			if i := strings.LastIndexAny(base, "/:"); i != -1 && !strings.HasSuffix(base[:i+1], "://") {
+				base = base[:i+1]
+				if strings.HasSuffix(base, "/") {
+					base = strings.TrimSuffix(base, "/")
+				}
+			}
+			continue
+		}
+		break
+	}
+	if strings.HasSuffix(base, ":") {
+		return base + url
+	}
+	return base + "/" + url
+}
+
+// getSSHAuth returns SSH authentication. An explicit key path is used as is;
+// otherwise it tries the agent first then the default key files.
+func getSSHAuth(keyPath, passphrase string) (transport.AuthMethod, error) {
+	if keyPath != "" {
+		keyAuth, err := ssh.NewPublicKeysFromFile("git", keyPath, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load SSH key %s: %w", keyPath, err)
+		}
+		return keyAuth, nil
+	}
+
 	// Try SSH agent first
 	auth, err := ssh.NewSSHAgentAuth("git")
 	if err == nil {
-		return auth
+		return auth, nil
 	}
 
 	// Fall back to default SSH key
 	home, _ := os.UserHomeDir()
-	keyPath := filepath.Join(home, ".ssh", "id_rsa")
+	keyPath = filepath.Join(home, ".ssh", "id_rsa")
 	if _, err := os.Stat(keyPath); os.IsNotExist(err) {
 		keyPath = filepath.Join(home, ".ssh", "id_ed25519")
 	}
 
 	keyAuth, err := ssh.NewPublicKeysFromFile("git", keyPath, "")
 	if err == nil {
-		return keyAuth
+		return keyAuth, nil
 	}
 
 	// No auth available (for public repos)
-	return nil
+	return nil, nil
 }
 
-// Status returns the status of a git repository
+// KeyNeedsPassphrase reports whether the private key file is encrypted
+func KeyNeedsPassphrase(keyPath string) (bool, error) {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read SSH key: %w", err)
+	}
+	_, err = cryptossh.ParseRawPrivateKey(data)
+	var missing *cryptossh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to parse SSH key %s: %w", keyPath, err)
+	}
+	return false, nil
+}
+
+// Status returns the status of a git repository, comparing the current
+// branch against the same branch on the given remote.
 // Uses git CLI for speed
-func Status(path string) (*RepoStatus, error) {
-	result := &RepoStatus{}
+func Status(path, remote string) (*RepoStatus, error) {
+	return StatusContext(context.Background(), path, remote)
+}
+
+// StatusContext is like Status but stops when ctx is done
+func StatusContext(ctx context.Context, path, remote string) (*RepoStatus, error) {
+	return StatusWithOptions(ctx, path, remote, StatusOptions{})
+}
 
-	// Get current branch or commit hash if detached
-	branch, err := gitCommand(path, "rev-parse", "--abbrev-ref", "HEAD")
+// StatusOptions configures StatusWithOptions
+type StatusOptions struct {
+	SkipRemote bool // leave out ahead/behind
+
+	// Fetch, if set, runs in StatusEachContext's worker before each status
+	// is read, with its own timeout, so fetches share the jobs. Its error
+	// is the result's FetchErr; the status is read either way.
+	Fetch func(ctx context.Context, target StatusTarget) error
+}
+
+// StatusWithOptions is like StatusContext with options
+func StatusWithOptions(ctx context.Context, path, remote string, opts StatusOptions) (*RepoStatus, error) {
+	result := &RepoStatus{Remote: remote, RemoteSkipped: opts.SkipRemote}
+
+	// Branch, upstream, ahead/behind, and changed files in one call
+	output, err := gitCommand(ctx, path, "status", "--porcelain=v2", "--branch")
 	if err != nil {
+		if strings.Contains(err.Error(), "detected dubious ownership") {
+			return nil, ErrDubiousOwnership
+		}
+		// Only asked when status fails, so worktrees pay nothing for it
+		if isBare(ctx, path) {
+			return bareStatus(ctx, path, remote)
+		}
 		return nil, err
 	}
-	branch = strings.TrimSpace(branch)
+	status := parsePorcelainV2(output)
+	result.DirtyFiles = status.changed
+	result.IsDirty = result.DirtyFiles > 0
 
-	if branch == "HEAD" {
-		// Detached HEAD - get short hash
-		hash, err := gitCommand(path, "rev-parse", "--short", "HEAD")
+	if status.head == "(detached)" {
+		// Abbreviate the hash the way git would
+		hash, err := gitCommand(ctx, path, "rev-parse", "--short", "HEAD")
 		if err != nil {
 			return nil, err
 		}
 		result.Branch = strings.TrimSpace(hash)
 		result.IsDetached = true
 	} else {
-		result.Branch = branch
-		result.IsDetached = false
+		result.Branch = status.head
+	}
+
+	// Ahead/behind come with the status when the branch tracks the same
+	// branch on remote; any other upstream needs counting against remote
+	if !result.IsDetached && !opts.SkipRemote {
+		if status.hasCounts && status.upstream == remote+"/"+result.Branch {
+			result.Ahead, result.Behind = status.ahead, status.behind
+		} else {
+			result.Ahead, result.Behind, result.NoTracking = getAheadBehind(ctx, path, remote, result.Branch)
+		}
+	}
+
+	setLastCommit(ctx, path, result)
+
+	// FETCH_HEAD is rewritten on every fetch, so its mtime says how current
+	// Ahead/Behind are
+	result.LastFetch = getLastFetch(ctx, path)
+
+	// Check for an interrupted rebase/merge/cherry-pick
+	result.Operation = getOperation(ctx, path)
+
+	// Record where origin points, to compare against the configured URL
+	result.RemoteURL, _ = remoteURL(ctx, path, CloneRemote)
+
+	// The helpers above treat failures as "unknown", so a deadline hit
+	// partway through would otherwise yield a plausible but wrong status
+	if ctx.Err() != nil {
+		return nil, contextError(ctx, nil)
+	}
+
+	return result, nil
+}
+
+// isBare reports whether the repository at path is bare
+func isBare(ctx context.Context, path string) bool {
+	output, err := gitCommand(ctx, path, "rev-parse", "--is-bare-repository")
+	return err == nil && strings.TrimSpace(output) == "true"
+}
+
+// bareStatus reads what applies to a bare repository: the branch HEAD names,
+// the last commit, the last fetch, and the origin URL. There's nothing to be
+// dirty, and mirrors keep the remote's branches as their own, so no
+// ahead/behind either.
+func bareStatus(ctx context.Context, path, remote string) (*RepoStatus, error) {
+	result := &RepoStatus{Remote: remote, IsBare: true}
+	if branch, err := gitCommand(ctx, path, "symbolic-ref", "--short", "HEAD"); err == nil {
+		result.Branch = strings.TrimSpace(branch)
+	}
+	setLastCommit(ctx, path, result)
+	result.LastFetch = getLastFetch(ctx, path)
+	result.RemoteURL, _ = remoteURL(ctx, path, CloneRemote)
+	if ctx.Err() != nil {
+		return nil, contextError(ctx, nil)
+	}
+	return result, nil
+}
+
+// ErrNoFingerprint is returned by StatusFingerprint for repositories it
+// can't summarize without running git, like linked worktrees
+var ErrNoFingerprint = errors.New("no status fingerprint")
+
+// StatusFingerprint summarizes the files a Status of the repository at path
+// against remote depends on, without running git: the modification time and
+// size of HEAD, the checked-out branch's ref and its remote-tracking ref on
+// remote, packed-refs, the index, FETCH_HEAD, the git config, and the
+// worktree's top directory. It changes with commits, checkouts, staging,
+// fetches, and pushes, but not with edits to tracked files that haven't
+// been staged, so a status cached under it can miss those.
+func StatusFingerprint(path, remote string) (string, error) {
+	gitDir := filepath.Join(path, ".git")
+	if info, err := os.Stat(gitDir); err != nil || !info.IsDir() {
+		return "", ErrNoFingerprint
+	}
+
+	files := []string{"HEAD", "packed-refs", "index", "FETCH_HEAD", "config"}
+	for _, marker := range operationMarkers {
+		files = append(files, marker.name)
+	}
+	if head, err := os.ReadFile(filepath.Join(gitDir, "HEAD")); err == nil {
+		if ref, ok := strings.CutPrefix(strings.TrimSpace(string(head)), "ref: "); ok {
+			files = append(files, filepath.FromSlash(ref))
+			// What ahead/behind count against, which a push moves alone
+			if branch, ok := strings.CutPrefix(ref, "refs/heads/"); ok {
+				files = append(files, filepath.FromSlash("refs/remotes/"+remote+"/"+branch))
+			}
+		}
 	}
 
-	// Get dirty files count using git status --porcelain
-	status, err := gitCommand(path, "status", "--porcelain")
+	var b strings.Builder
+	for _, name := range files {
+		if info, err := os.Stat(filepath.Join(gitDir, name)); err == nil {
+			fmt.Fprintf(&b, "%s:%d:%d;", name, info.ModTime().UnixNano(), info.Size())
+		}
+	}
+	info, err := os.Stat(path)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	if status == "" {
-		result.DirtyFiles = 0
-	} else {
-		result.DirtyFiles = len(strings.Split(strings.TrimSpace(status), "\n"))
+	fmt.Fprintf(&b, ".:%d", info.ModTime().UnixNano())
+	return b.String(), nil
+}
+
+// StatusResult is the outcome of reading one repository's status in
+// StatusAll: its Status, or the Err that prevented it. FetchErr is why
+// StatusOptions.Fetch failed, if it did.
+type StatusResult struct {
+	Status   *RepoStatus
+	Err      error
+	FetchErr error
+}
+
+// StatusTarget names a repository for StatusAllContext and the remote its
+// branch is compared against
+type StatusTarget struct {
+	Path   string
+	Remote string
+}
+
+// StatusAll reads the status of the repositories at paths, comparing
+// against CloneRemote, with at most jobs running at a time (at least one).
+// Results are keyed by path.
+func StatusAll(paths []string, jobs int) map[string]StatusResult {
+	targets := make([]StatusTarget, len(paths))
+	for i, path := range paths {
+		targets[i] = StatusTarget{Path: path, Remote: CloneRemote}
 	}
-	result.IsDirty = result.DirtyFiles > 0
+	return StatusAllContext(context.Background(), targets, jobs, 0, StatusOptions{})
+}
 
-	// Check ahead/behind for current branch
-	if !result.IsDetached {
-		result.Ahead, result.Behind, result.NoTracking = getAheadBehind(path, result.Branch)
+// StatusAllContext is like StatusAll with a remote per repository and
+// options. Each status is stopped after timeout (0 for none) or when ctx is
+// done, which leaves the remaining repositories with ctx's error.
+func StatusAllContext(ctx context.Context, targets []StatusTarget, jobs int, timeout time.Duration, opts StatusOptions) map[string]StatusResult {
+	results := make(map[string]StatusResult, len(targets))
+	StatusEachContext(ctx, targets, jobs, timeout, opts, func(target StatusTarget, result StatusResult) {
+		results[target.Path] = result
+	})
+	return results
+}
+
+// StatusEachContext is like StatusAllContext but hands each result to each
+// as soon as it's read, in the order the reads finish. Calls to each don't
+// overlap, and all of them have returned when StatusEachContext does.
+func StatusEachContext(ctx context.Context, targets []StatusTarget, jobs int, timeout time.Duration, opts StatusOptions, each func(StatusTarget, StatusResult)) {
+	jobs = max(1, min(jobs, len(targets)))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	queue := make(chan StatusTarget)
+	for range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for target := range queue {
+				var fetchErr error
+				if opts.Fetch != nil {
+					opCtx, cancel := withTimeout(ctx, timeout)
+					fetchErr = opts.Fetch(opCtx, target)
+					cancel()
+				}
+				opCtx, cancel := withTimeout(ctx, timeout)
+				status, err := StatusWithOptions(opCtx, target.Path, target.Remote, opts)
+				cancel()
+
+				mu.Lock()
+				each(target, StatusResult{Status: status, Err: err, FetchErr: fetchErr})
+				mu.Unlock()
+			}
+		}()
 	}
+	for _, target := range targets {
+		queue <- target
+	}
+	close(queue)
+	wg.Wait()
+}
 
-	// Get last commit time
-	result.LastCommitTime = getLastCommitTime(path)
+// withTimeout derives a context from ctx that's done after timeout, or only
+// with ctx for a timeout of 0
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
 
-	return result, nil
+// porcelainStatus is what parsePorcelainV2 reads from git status
+// --porcelain=v2 --branch
+type porcelainStatus struct {
+	head      string // branch name, or "(detached)"
+	upstream  string // e.g. "origin/main", empty if none
+	hasCounts bool   // ahead and behind were reported; not when the upstream is gone
+	ahead     int
+	behind    int
+	changed   int // changed and untracked files
+}
+
+// parsePorcelainV2 parses the output of git status --porcelain=v2 --branch:
+// "# branch.*" header lines followed by a line per changed or untracked file
+func parsePorcelainV2(output string) porcelainStatus {
+	var status porcelainStatus
+	for _, line := range strings.Split(output, "\n") {
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "# branch.head "):
+			status.head = strings.TrimPrefix(line, "# branch.head ")
+		case strings.HasPrefix(line, "# branch.upstream "):
+			status.upstream = strings.TrimPrefix(line, "# branch.upstream ")
+		case strings.HasPrefix(line, "# branch.ab "):
+			if _, err := fmt.Sscanf(strings.TrimPrefix(line, "# branch.ab "), "+%d -%d", &status.ahead, &status.behind); err == nil {
+				status.hasCounts = true
+			}
+		case strings.HasPrefix(line, "#"):
+			// Other headers (branch.oid, stash) aren't needed
+		default:
+			status.changed++
+		}
+	}
+	return status
 }
 
-// getLastCommitTime returns the time of the most recent commit
-func getLastCommitTime(repoPath string) time.Time {
-	// Use %ct for committer date as Unix timestamp (faster to parse)
-	output, err := gitCommand(repoPath, "log", "-1", "--format=%ct")
+// CurrentBranch returns the branch checked out in the repository at path.
+// If HEAD is detached, it returns the short commit hash and detached = true.
+func CurrentBranch(path string) (branch string, detached bool, err error) {
+	return currentBranch(context.Background(), path)
+}
+
+func currentBranch(ctx context.Context, path string) (string, bool, error) {
+	branch, err := gitCommand(ctx, path, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", false, err
+	}
+	branch = strings.TrimSpace(branch)
+	if branch != "HEAD" {
+		return branch, false, nil
+	}
+
+	// Detached HEAD - get short hash
+	hash, err := gitCommand(ctx, path, "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return "", false, err
+	}
+	return strings.TrimSpace(hash), true, nil
+}
+
+// RemoteURL returns the URL configured for remote in the repository at path
+func RemoteURL(path, remote string) (string, error) {
+	return remoteURL(context.Background(), path, remote)
+}
+
+func remoteURL(ctx context.Context, path, remote string) (string, error) {
+	url, err := gitCommand(ctx, path, "config", "--get", "remote."+remote+".url")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(url), nil
+}
+
+// SetRemoteURL points remote in the repository at path to url
+func SetRemoteURL(path, remote, url string) error {
+	_, err := gitCommand(context.Background(), path, "remote", "set-url", remote, url)
+	return err
+}
+
+// Trust adds path to git's global safe.directory list, so git works in a
+// repository owned by another user. It reports false without changing
+// anything when the path, or every path ("*"), is already listed.
+func Trust(path string) (bool, error) {
+	ctx := context.Background()
+	// --get-all fails with nothing listed yet, which is just an empty list
+	listed, _ := gitCommand(ctx, "", "config", "--global", "--get-all", "safe.directory")
+	for _, entry := range strings.Split(listed, "\n") {
+		if entry = strings.TrimSpace(entry); entry == path || entry == "*" {
+			return false, nil
+		}
+	}
+	if _, err := gitCommand(ctx, "", "config", "--global", "--add", "safe.directory", path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SameURL reports whether two git URLs refer to the same repository,
+// ignoring a trailing ".git" or "/", the user, the port, and the difference
+// between scp-style (git@host:path), ssh://, and https:// forms.
+func SameURL(a, b string) bool {
+	return normalizeURL(a) == normalizeURL(b)
+}
+
+// normalizeURL reduces a git URL to "host/path" (or just the path for local
+// repositories) for SameURL
+func normalizeURL(url string) string {
+	u := strings.TrimSuffix(strings.TrimSpace(url), "/")
+	u = strings.TrimSuffix(strings.TrimSuffix(u, ".git"), "/")
+
+	// scheme://[user@]host[:port]/path
+	if idx := strings.Index(u, "://"); idx != -1 {
+		host, path, _ := strings.Cut(u[idx+3:], "/")
+		if at := strings.LastIndex(host, "@"); at != -1 {
+			host = host[at+1:]
+		}
+		host, _, _ = strings.Cut(host, ":")
+		return strings.ToLower(host) + "/" + path
+	}
+
+	// scp-like: [user@]host:path. A colon after a slash is part of a local path.
+	if colon := strings.Index(u, ":"); colon != -1 && !strings.Contains(u[:colon], "/") {
+		host := u[:colon]
+		if at := strings.LastIndex(host, "@"); at != -1 {
+			host = host[at+1:]
+		}
+		return strings.ToLower(host) + "/" + strings.TrimPrefix(u[colon+1:], "/")
+	}
+
+	return u
+}
+
+// getOperation returns the operation in progress in the repository, if any.
+// The git dir is resolved via the CLI so linked worktrees (where .git is a
+// file) are handled.
+func getOperation(ctx context.Context, repoPath string) string {
+	gitDir, err := gitCommand(ctx, repoPath, "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return ""
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	for _, marker := range operationMarkers {
+		if _, err := os.Stat(filepath.Join(gitDir, marker.name)); err == nil {
+			return marker.operation
+		}
+	}
+	return ""
+}
+
+// getLastFetch returns the modification time of FETCH_HEAD, or zero if the
+// repository was never fetched (a fresh clone has no FETCH_HEAD)
+func getLastFetch(ctx context.Context, repoPath string) time.Time {
+	fetchHead, err := gitCommand(ctx, repoPath, "rev-parse", "--git-path", "FETCH_HEAD")
 	if err != nil {
 		return time.Time{}
 	}
-	timestamp, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	fetchHead = strings.TrimSpace(fetchHead)
+	if !filepath.IsAbs(fetchHead) {
+		fetchHead = filepath.Join(repoPath, fetchHead)
+	}
+	info, err := os.Stat(fetchHead)
 	if err != nil {
 		return time.Time{}
 	}
-	return time.Unix(timestamp, 0)
+	return info.ModTime()
+}
+
+// CommitInfo is the metadata of one commit, as LastCommit and Log read it
+type CommitInfo struct {
+	Hash    string    // abbreviated
+	Time    time.Time // committer date
+	Author  string    // author name
+	Subject string    // first line of the message
+}
+
+// commitFormat is the git log --format that parseCommit reads: NUL
+// separated, since subjects can contain anything but newlines, with the
+// committer date as a Unix timestamp (faster to parse)
+const commitFormat = "--format=%h%x00%ct%x00%an%x00%s"
+
+// parseCommit parses a line of git log output in commitFormat
+func parseCommit(line string) (CommitInfo, bool) {
+	fields := strings.SplitN(line, "\x00", 4)
+	if len(fields) != 4 {
+		return CommitInfo{}, false
+	}
+	commit := CommitInfo{Hash: fields[0], Author: fields[2], Subject: fields[3]}
+	if timestamp, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+		commit.Time = time.Unix(timestamp, 0)
+	}
+	return commit, true
+}
+
+// LastCommit returns the commit HEAD of the repository at path points to.
+// It fails for a repository without commits.
+func LastCommit(path string) (CommitInfo, error) {
+	return LastCommitContext(context.Background(), path)
+}
+
+// LastCommitContext is like LastCommit but kills git when ctx is done
+func LastCommitContext(ctx context.Context, path string) (CommitInfo, error) {
+	output, err := gitCommand(ctx, path, "log", "-1", "--no-color", commitFormat, "HEAD", "--")
+	if err != nil {
+		return CommitInfo{}, err
+	}
+	commit, ok := parseCommit(strings.TrimSuffix(output, "\n"))
+	if !ok {
+		return CommitInfo{}, fmt.Errorf("unexpected git log output %q", output)
+	}
+	return commit, nil
+}
+
+// setLastCommit fills in result's last commit fields, left empty for a
+// repository without commits
+func setLastCommit(ctx context.Context, path string, result *RepoStatus) {
+	if commit, err := LastCommitContext(ctx, path); err == nil {
+		result.LastCommitTime, result.Author, result.Subject = commit.Time, commit.Author, commit.Subject
+	}
+}
+
+// trace receives a line per git command when set, see SetTrace
+var trace io.Writer
+
+// SetTrace logs every git invocation (its arguments and directory) to w, and
+// for failed ones git's stderr. A nil w turns logging off.
+func SetTrace(w io.Writer) {
+	trace = w
+}
+
+// traceCommand logs cmd to the trace writer, if set
+func traceCommand(cmd *exec.Cmd) {
+	if trace == nil {
+		return
+	}
+	args := make([]string, len(cmd.Args))
+	for i, arg := range cmd.Args {
+		args[i] = arg
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'") {
+			args[i] = strconv.Quote(arg)
+		}
+	}
+	fmt.Fprintf(trace, "+ %s  (in %s)\n", strings.Join(args, " "), cmd.Dir)
 }
 
-// gitCommand runs a git command and returns stdout
-func gitCommand(repoPath string, args ...string) (string, error) {
-	cmd := exec.Command("git", args...)
+// traceFailure logs a failed command's error and stderr to the trace writer,
+// if set
+func traceFailure(err error, stderr string) {
+	if trace == nil {
+		return
+	}
+	fmt.Fprintf(trace, "  %v\n", err)
+	for _, line := range strings.Split(strings.TrimSpace(stderr), "\n") {
+		if line != "" {
+			fmt.Fprintf(trace, "  | %s\n", line)
+		}
+	}
+}
+
+// slots holds a token per running git process when the number is capped,
+// see SetMaxConcurrency
+var slots chan struct{}
+
+// SetMaxConcurrency caps the git processes this package runs at once at n,
+// across all goroutines, so parallel work can't exhaust process or file
+// descriptor limits. n < 1 removes the cap. Call it before starting any git
+// operations; operations waiting for a slot stop when their context is done.
+func SetMaxConcurrency(n int) {
+	if n < 1 {
+		slots = nil
+		return
+	}
+	slots = make(chan struct{}, n)
+}
+
+// acquire waits for a slot to run a git process in, see SetMaxConcurrency.
+// The returned release frees it.
+func acquire(ctx context.Context) (release func(), err error) {
+	// The channel is captured, so a SetMaxConcurrency while the slot is
+	// held can't make release wait on another one
+	s := slots
+	if s == nil {
+		return func() {}, nil
+	}
+	select {
+	case s <- struct{}{}:
+		return func() { <-s }, nil
+	case <-ctx.Done():
+		return nil, contextError(ctx, ctx.Err())
+	}
+}
+
+// gitCommand runs a git command and returns stdout. The process is killed
+// when ctx is done.
+func gitCommand(ctx context.Context, repoPath string, args ...string) (string, error) {
+	return gitCommandEnv(ctx, repoPath, nil, args...)
+}
+
+// gitCommandEnv is gitCommand with env added to the environment
+func gitCommandEnv(ctx context.Context, repoPath string, env []string, args ...string) (string, error) {
+	release, err := acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
 	cmd.Dir = repoPath
+	cmd.Stderr = &stderr
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	traceCommand(cmd)
 	output, err := cmd.Output()
 	if err != nil {
-		return "", err
+		traceFailure(err, stderr.String())
+		if ctx.Err() != nil {
+			return "", contextError(ctx, err)
+		}
+		return "", commandError(err, stderr.String())
 	}
 	return string(output), nil
 }
 
-// getAheadBehind returns how many commits the current branch is ahead/behind its remote tracking branch
+// commandError adds what git said on stderr to the error of a failed
+// command, which is otherwise only "exit status 128". Its fatal/error lines
+// are preferred over anything else it printed.
+func commandError(err error, stderr string) error {
+	detail := errorLines(stderr)
+	if detail == "" {
+		var lines []string
+		for _, line := range strings.Split(stderr, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		detail = strings.Join(lines, "; ")
+	}
+	if detail == "" {
+		return err
+	}
+	return fmt.Errorf("%w: %s", err, detail)
+}
+
+// getAheadBehind returns how many commits the current branch is ahead/behind
+// the same branch on remoteName. A missing remote or remote branch is
+// reported as noTracking.
 // Uses git rev-list --count for efficiency
-func getAheadBehind(repoPath, branchName string) (ahead, behind int, noTracking bool) {
-	remote := "origin/" + branchName
+func getAheadBehind(ctx context.Context, repoPath, remoteName, branchName string) (ahead, behind int, noTracking bool) {
+	// Fully qualified, here and in the ranges, so a local branch or tag that
+	// happens to be named like remote/branch can't stand in for it
+	remote := "refs/remotes/" + remoteName + "/" + branchName
 
 	// Check if remote tracking branch exists
-	cmd := exec.Command("git", "rev-parse", "--verify", remote)
-	cmd.Dir = repoPath
-	if err := cmd.Run(); err != nil {
+	if _, err := gitCommand(ctx, repoPath, "rev-parse", "--verify", "--quiet", remote); err != nil {
 		return 0, 0, true
 	}
 
 	// Count commits ahead (local commits not in remote)
-	ahead = revListCount(repoPath, remote+"..HEAD")
+	ahead = revListCount(ctx, repoPath, remote+"..HEAD")
 
 	// Count commits behind (remote commits not in local)
-	behind = revListCount(repoPath, "HEAD.."+remote)
+	behind = revListCount(ctx, repoPath, "HEAD.."+remote)
 
 	return ahead, behind, false
 }
 
 // revListCount runs git rev-list --count and returns the count
-func revListCount(repoPath, revRange string) int {
-	cmd := exec.Command("git", "rev-list", "--count", revRange)
-	cmd.Dir = repoPath
-	output, err := cmd.Output()
+func revListCount(ctx context.Context, repoPath, revRange string) int {
+	output, err := gitCommand(ctx, repoPath, "rev-list", "--count", revRange)
 	if err != nil {
 		return 0
 	}
-	count, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	count, err := strconv.Atoi(strings.TrimSpace(output))
 	if err != nil {
 		return 0
 	}
 	return count
 }
 
-// Exists checks if a path is a git repository
+// Checkout switches the repository at path to branch. An existing local
+// branch is checked out; otherwise a branch of the same name on remote is
+// checked out tracking it. With create, a missing branch is started from
+// remote's default branch, see RemoteHeadContext.
+func Checkout(path, remote, branch string, create bool) error {
+	return CheckoutContext(context.Background(), path, remote, branch, create)
+}
+
+// CheckoutContext is like Checkout but kills git when ctx is done
+func CheckoutContext(ctx context.Context, path, remote, branch string, create bool) error {
+	if refExists(ctx, path, "refs/heads/"+branch) {
+		_, err := gitCommand(ctx, path, "checkout", "-q", branch)
+		return err
+	}
+	remoteBranch := remote + "/" + branch
+	if refExists(ctx, path, "refs/remotes/"+remoteBranch) {
+		_, err := gitCommand(ctx, path, "checkout", "-q", "-b", branch, "--track", remoteBranch)
+		return err
+	}
+	if ctx.Err() != nil {
+		return contextError(ctx, nil)
+	}
+	if !create {
+		return fmt.Errorf("branch %s does not exist", branch)
+	}
+
+	start, err := RemoteHeadContext(ctx, path, remote)
+	if err != nil {
+		return fmt.Errorf("can't create %s: %w", branch, err)
+	}
+	// --no-track, or the new branch would track the default branch
+	_, err = gitCommand(ctx, path, "checkout", "-q", "--no-track", "-b", branch, start)
+	return err
+}
+
+// RemoteHeadContext returns the default branch of remote in the repository
+// at path as a remote-tracking branch, e.g. origin/main: what remote's HEAD
+// points to, or as clones made with go-git don't record that, what remote
+// reports as its HEAD (git ls-remote --symref). Unlike DefaultBranchContext
+// it doesn't guess main or master.
+func RemoteHeadContext(ctx context.Context, path, remote string) (string, error) {
+	if head, err := gitCommand(ctx, path, "symbolic-ref", "--quiet", "--short", "refs/remotes/"+remote+"/HEAD"); err == nil {
+		return strings.TrimSpace(head), nil
+	}
+	if ctx.Err() != nil {
+		return "", contextError(ctx, nil)
+	}
+	output, err := gitCommand(ctx, path, "ls-remote", "--symref", remote, "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to read the default branch of %s: %w", remote, err)
+	}
+	// "ref: refs/heads/main\tHEAD", before the line with HEAD's commit
+	for _, line := range strings.Split(output, "\n") {
+		name, ok := strings.CutPrefix(line, "ref: refs/heads/")
+		if !ok {
+			continue
+		}
+		name, _, _ = strings.Cut(name, "\t")
+		head := remote + "/" + name
+		if !refExists(ctx, path, "refs/remotes/"+head) {
+			return "", fmt.Errorf("default branch %s hasn't been fetched", head)
+		}
+		return head, nil
+	}
+	return "", fmt.Errorf("%s doesn't report a default branch", remote)
+}
+
+// PullResult is what Pull did
+type PullResult struct {
+	Updated     bool // HEAD moved
+	Autostashed bool // uncommitted changes were stashed for the pull and reapplied
+	StashKept   bool // reapplying them conflicted, so they're still in the stash
+}
+
+// Pull integrates remote's branch into the current branch of the repository
+// at path the way strategy says: "ff-only" only fast-forwards, "rebase"
+// replays local commits on top, "merge" creates a merge commit where it
+// can't fast-forward. A rebase or merge that stops on conflicts is aborted,
+// so the repository is left as it was. With autostash, uncommitted changes
+// to tracked files are stashed first and reapplied afterwards, even when
+// the pull fails.
+func Pull(path, remote, branch, strategy string, autostash bool) (PullResult, error) {
+	return PullContext(context.Background(), path, remote, branch, strategy, autostash)
+}
+
+// PullContext is like Pull but kills git when ctx is done
+func PullContext(ctx context.Context, path, remote, branch, strategy string, autostash bool) (PullResult, error) {
+	var result PullResult
+	args := []string{"pull", "--quiet", "--no-edit"}
+	switch strategy {
+	case "ff-only":
+		args = append(args, "--ff-only")
+	case "rebase":
+		args = append(args, "--rebase")
+	case "merge":
+		// --ff overrides pull.ff = only in the user's git config
+		args = append(args, "--no-rebase", "--ff")
+	default:
+		return result, fmt.Errorf("unknown pull strategy %q", strategy)
+	}
+
+	before, err := gitCommand(ctx, path, "rev-parse", "HEAD")
+	if err != nil {
+		return result, err
+	}
+	if autostash {
+		changes, err := gitCommand(ctx, path, "status", "--porcelain", "--untracked-files=no")
+		if err != nil {
+			return result, err
+		}
+		result.Autostashed = strings.TrimSpace(changes) != ""
+	}
+	stashes := 0
+	if result.Autostashed {
+		if stashes, err = StashCountContext(ctx, path); err != nil {
+			return result, err
+		}
+		if strategy == "rebase" {
+			// Rebasing stashes and reapplies by itself, and keeps the stash
+			// when reapplying conflicts
+			args = append(args, "--autostash")
+		} else if _, err := gitCommand(ctx, path, "stash", "push", "--quiet", "-m", "arbol pull autostash"); err != nil {
+			return result, fmt.Errorf("failed to stash changes: %w", err)
+		}
+	}
+
+	_, pullErr := gitCommand(ctx, path, append(args, "--", remote, branch)...)
+	if pullErr != nil && ctx.Err() == nil && strategy != "ff-only" {
+		// Fails with "no rebase/merge in progress" unless it stopped on
+		// conflicts
+		if _, abortErr := gitCommand(ctx, path, strategy, "--abort"); abortErr == nil {
+			pullErr = fmt.Errorf("conflicts with %s/%s, %s aborted", remote, branch, strategy)
+		}
+	}
+	switch {
+	case !result.Autostashed:
+	case strategy != "rebase":
+		// Also after a failed pull, so the changes aren't left stashed away.
+		// Once ctx is done git can't run, and they stay in the stash.
+		if ctx.Err() != nil {
+			result.StashKept = true
+		} else if _, err := gitCommand(ctx, path, "stash", "pop", "--quiet"); err != nil {
+			result.StashKept = true
+		}
+	case pullErr == nil:
+		after, err := StashCountContext(ctx, path)
+		if err != nil {
+			return result, err
+		}
+		result.StashKept = after > stashes
+	}
+	if pullErr != nil {
+		return result, pullErr
+	}
+
+	after, err := gitCommand(ctx, path, "rev-parse", "HEAD")
+	if err != nil {
+		return result, err
+	}
+	result.Updated = before != after
+	return result, nil
+}
+
+// StashCount returns how many entries the stash of the repository at path
+// holds
+func StashCount(path string) (int, error) {
+	return StashCountContext(context.Background(), path)
+}
+
+// StashCountContext is like StashCount but kills git when ctx is done
+func StashCountContext(ctx context.Context, path string) (int, error) {
+	output, err := gitCommand(ctx, path, "stash", "list", "--format=%gd")
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "stash@{") {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// UnpushedCount returns how many commits on HEAD of the repository at path
+// no remote-tracking branch has, i.e. what only this clone holds. A HEAD
+// without commits has none.
+func UnpushedCount(path string) (int, error) {
+	return UnpushedCountContext(context.Background(), path)
+}
+
+// UnpushedCountContext is like UnpushedCount but kills git when ctx is done
+func UnpushedCountContext(ctx context.Context, path string) (int, error) {
+	if !refExists(ctx, path, "HEAD") {
+		return 0, contextError(ctx, nil)
+	}
+	output, err := gitCommand(ctx, path, "rev-list", "--count", "HEAD", "--not", "--remotes")
+	if err != nil {
+		return 0, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(output))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected rev-list output %q", output)
+	}
+	return count, nil
+}
+
+// HasStash reports whether the repository at path has stashed changes
+func HasStash(path string) (bool, error) {
+	count, err := StashCount(path)
+	return count > 0, err
+}
+
+// LogOptions filters the commits Log returns, like the git log flags of the
+// same name
+type LogOptions struct {
+	Since  string // anything git log --since takes, e.g. "1.week" or "2024-01-31"
+	Author string // pattern matched against the author's name and email
+	Limit  int    // at most this many commits, 0 for all
+}
+
+// Log returns the commits on HEAD of the repository at path that match opts,
+// newest first. A repository without commits has none.
+func Log(path string, opts LogOptions) ([]CommitInfo, error) {
+	return LogContext(context.Background(), path, opts)
+}
+
+// LogContext is like Log but kills git when ctx is done
+func LogContext(ctx context.Context, path string, opts LogOptions) ([]CommitInfo, error) {
+	if !refExists(ctx, path, "HEAD") {
+		if ctx.Err() != nil {
+			return nil, contextError(ctx, nil)
+		}
+		return nil, nil
+	}
+	args := []string{"log", "--no-color", commitFormat}
+	if opts.Since != "" {
+		args = append(args, "--since="+opts.Since)
+	}
+	if opts.Author != "" {
+		args = append(args, "--author="+opts.Author)
+	}
+	if opts.Limit > 0 {
+		args = append(args, "--max-count="+strconv.Itoa(opts.Limit))
+	}
+	output, err := gitCommand(ctx, path, append(args, "HEAD", "--")...)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []CommitInfo
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		if commit, ok := parseCommit(line); ok {
+			commits = append(commits, commit)
+		}
+	}
+	return commits, nil
+}
+
+// Branches returns the local branches of the repository at path, sorted,
+// and the checked out one; that's "" on a detached HEAD
+func Branches(path string) ([]string, string, error) {
+	return BranchesContext(context.Background(), path)
+}
+
+// BranchesContext is like Branches but kills git when ctx is done
+func BranchesContext(ctx context.Context, path string) ([]string, string, error) {
+	branches, err := listBranches(ctx, path, "refs/heads/", "")
+	if err != nil {
+		return nil, "", err
+	}
+	// Fails on a detached HEAD
+	current, _ := gitCommand(ctx, path, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if ctx.Err() != nil {
+		return nil, "", contextError(ctx, nil)
+	}
+	return branches, strings.TrimSpace(current), nil
+}
+
+// RemoteBranchesContext returns the remote-tracking branches of the
+// repository at path, sorted, e.g. origin/main
+func RemoteBranchesContext(ctx context.Context, path string) ([]string, error) {
+	return listBranches(ctx, path, "refs/remotes/", "")
+}
+
+// MergedBranchesContext returns the local and remote-tracking branches of
+// the repository at path whose tip is reachable from target, so deleting
+// them loses no commits
+func MergedBranchesContext(ctx context.Context, path, target string) ([]string, error) {
+	local, err := listBranches(ctx, path, "refs/heads/", target)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := listBranches(ctx, path, "refs/remotes/", target)
+	if err != nil {
+		return nil, err
+	}
+	return append(local, remote...), nil
+}
+
+// DefaultBranchContext returns the default branch of remote in the
+// repository at path as a remote-tracking branch, e.g. origin/main: what
+// remote's HEAD points to, else main or master if remote has one
+func DefaultBranchContext(ctx context.Context, path, remote string) (string, error) {
+	if head, err := gitCommand(ctx, path, "symbolic-ref", "--quiet", "--short", "refs/remotes/"+remote+"/HEAD"); err == nil {
+		return strings.TrimSpace(head), nil
+	}
+	for _, name := range []string{"main", "master"} {
+		if refExists(ctx, path, "refs/remotes/"+remote+"/"+name) {
+			return remote + "/" + name, nil
+		}
+	}
+	if ctx.Err() != nil {
+		return "", contextError(ctx, nil)
+	}
+	return "", fmt.Errorf("default branch of %s is unknown, set it with 'git remote set-head %s --auto'", remote, remote)
+}
+
+// listBranches returns the branches under prefix (refs/heads/ or
+// refs/remotes/) with prefix removed, leaving out symbolic refs like
+// origin/HEAD. A non-empty merged keeps only those merged into it.
+func listBranches(ctx context.Context, path, prefix, merged string) ([]string, error) {
+	args := []string{"for-each-ref", "--format=%(refname)%00%(symref)"}
+	if merged != "" {
+		args = append(args, "--merged="+merged)
+	}
+	output, err := gitCommand(ctx, path, append(args, prefix)...)
+	if err != nil {
+		return nil, err
+	}
+	var branches []string
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		ref, symref, _ := strings.Cut(line, "\x00")
+		if ref == "" || symref != "" {
+			continue
+		}
+		branches = append(branches, strings.TrimPrefix(ref, prefix))
+	}
+	return branches, nil
+}
+
+// refExists reports whether ref (e.g. refs/heads/main) exists
+func refExists(ctx context.Context, path, ref string) bool {
+	_, err := gitCommand(ctx, path, "rev-parse", "--verify", "--quiet", ref)
+	return err == nil
+}
+
+// Exists checks if a path is a git repository. Linked worktrees, where .git
+// is a file pointing into the main repository, count too; their shared
+// objects and refs are found through the main repository's commondir.
 func Exists(path string) bool {
-	_, err := git.PlainOpen(path)
+	_, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
 	return err == nil
 }
 
-// Fetch fetches all remotes and tags for a repository
-// Uses --progress to show output even when not a tty
-func Fetch(path string) error {
-	cmd := exec.Command("git", "fetch", "--all", "--tags", "--progress")
+// FindRepos walks root at most maxDepth directories deep and returns the
+// paths of the git repositories found, in lexical order. It doesn't descend
+// into repositories or hidden directories, and a missing root yields no
+// repos rather than an error.
+func FindRepos(root string, maxDepth int) ([]string, error) {
+	root = filepath.Clean(root)
+	var repos []string
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			// Unreadable subdirectories are skipped, not fatal
+			return nil
+		}
+		if !d.IsDir() || path == root {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if Exists(path) {
+			repos = append(repos, path)
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if depth := strings.Count(rel, string(filepath.Separator)) + 1; depth >= maxDepth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return repos, err
+}
+
+// Fetch fetches all remotes and tags for a repository, with prune also
+// deleting remote-tracking branches that are gone on the remote. git's
+// output goes to stdout and stderr as it runs (nil discards it); --progress
+// keeps the progress lines coming even when stderr isn't a tty.
+func Fetch(path string, prune bool, stdout, stderr io.Writer) error {
+	return FetchContext(context.Background(), path, prune, stdout, stderr)
+}
+
+// FetchContext is like Fetch but kills git when ctx is done. git's error
+// lines are also added to the returned error, so callers can tell why it
+// failed (see IsTransient).
+func FetchContext(ctx context.Context, path string, prune bool, stdout, stderr io.Writer) error {
+	args := []string{"fetch", "--all", "--tags", "--progress"}
+	if prune {
+		args = append(args, "--prune")
+	}
+	return runFetch(ctx, path, args, stdout, stderr)
+}
+
+// RemoteUpdateContext updates a mirror the way git intends: git remote
+// update, which fetches every remote into the refs the mirror refspec
+// names, pruning with prune. Output and errors are handled as in
+// FetchContext.
+func RemoteUpdateContext(ctx context.Context, path string, prune bool, stdout, stderr io.Writer) error {
+	args := []string{"remote", "update"}
+	if prune {
+		args = append(args, "--prune")
+	}
+	return runFetch(ctx, path, args, stdout, stderr)
+}
+
+// runFetch runs a fetching git command in path with its output going to
+// stdout and stderr (nil discards it), and git's error lines added to a
+// failure
+func runFetch(ctx context.Context, path string, args []string, stdout, stderr io.Writer) error {
+	release, err := acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	var captured bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
 	cmd.Dir = path
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	cmd.Stdout = stdout
+	cmd.Stderr = io.MultiWriter(stderr, &captured)
+	traceCommand(cmd)
+	err = cmd.Run()
+	if err != nil {
+		// git's stderr went to the caller's writer as it ran
+		traceFailure(err, "")
+	}
+	if ctx.Err() != nil {
+		return contextError(ctx, err)
+	}
+	if err != nil {
+		// Only git's error lines, as the rest is progress output
+		if lines := errorLines(captured.String()); lines != "" {
+			return fmt.Errorf("%w: %s", err, lines)
+		}
+		return err
+	}
+	return nil
+}
+
+// errorLines picks git's fatal/error lines (and ssh's) out of its stderr,
+// skipping progress output, and joins them with "; "
+func errorLines(stderr string) string {
+	var lines []string
+	for _, line := range strings.FieldsFunc(stderr, func(r rune) bool { return r == '\n' || r == '\r' }) {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "fatal:") || strings.HasPrefix(line, "error:") || strings.HasPrefix(line, "ssh:") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "; ")
+}
+
+// transientErrors are texts of clone/fetch failures worth retrying: network
+// hiccups and overloaded servers. Anything else, e.g. failed auth, a missing
+// repository, or an existing target, fails the same way again.
+var transientErrors = []string{
+	"connection refused",
+	"connection reset",
+	"connection timed out",
+	"i/o timeout",
+	"no route to host",
+	"network is unreachable",
+	"could not resolve host",
+	"temporary failure in name resolution",
+	"tls handshake timeout",
+	"unexpected eof",
+	"early eof",
+	"the remote end hung up unexpectedly",
+	"returned error: 50", // git over http, 5xx
+	"status code: 50",    // go-git over http, 5xx
+}
+
+// IsTransient reports whether a Clone or Fetch error looks like a network
+// hiccup that may succeed on a retry. Timeouts count; interruptions don't.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, ErrTimeout) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, text := range transientErrors {
+		if strings.Contains(msg, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchQuietContext fetches like FetchContext without printing anything, for
+// callers whose stdout is their own output (e.g. status JSON) or that were
+// asked to be quiet
+func FetchQuietContext(ctx context.Context, path string, prune bool) error {
+	args := []string{"fetch", "--all", "--tags", "--quiet"}
+	if prune {
+		args = append(args, "--prune")
+	}
+	_, err := gitCommand(ctx, path, args...)
+	return err
 }